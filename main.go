@@ -4,13 +4,13 @@ import (
 	"log"
 	"net/http"
 
-	// "os" // os is now used within config.LoadConfig()
-	"ollama-openai-proxy/src/config" // Add this import
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
 	"ollama-openai-proxy/src/handlers"
 	"ollama-openai-proxy/src/middleware"
 )
 
-// healthCheckHandler remains the same
+// healthCheckHandler answers HEAD / for health checks.
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodHead {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -25,30 +25,83 @@ func NotImplementedHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	cfg := config.LoadConfig() // Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %s", err)
+	}
+
+	registry, err := backends.NewRegistry(cfg)
+	if err != nil {
+		log.Fatalf("Invalid upstream client configuration: %s", err)
+	}
+	for _, backend := range registry.Backends() {
+		log.Printf("Backend %s: %s", backend.Name, backend.BaseURL)
+	}
+	log.Printf("Created models are kept in %s", cfg.ModelsFile)
 
 	mux := http.NewServeMux()
 
+	// The health check stays reachable without credentials
 	mux.HandleFunc("/", healthCheckHandler)
-	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+
+	// API routes are authenticated according to cfg.AuthMode
+	api := http.NewServeMux()
+	api.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
 		handlers.GetVersionHandler(w, r, cfg.Version)
 	})
-	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetModelsHandler(w, r, cfg.OpenAIBaseURL, cfg.OpenAIAllowedModels)
-	})
-	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ChatHandler(w, r, cfg.OpenAIBaseURL)
-	})
-	mux.HandleFunc("/api/generate", NotImplementedHandler)
-	mux.HandleFunc("/api/pull", NotImplementedHandler)
-	mux.HandleFunc("/api/push", NotImplementedHandler)
-	mux.HandleFunc("/api/create", NotImplementedHandler)
-	mux.HandleFunc("/api/ps", NotImplementedHandler)
-	mux.HandleFunc("/api/copy", NotImplementedHandler)
-	mux.HandleFunc("/api/delete", NotImplementedHandler)
-	mux.HandleFunc("/api/show", NotImplementedHandler)
-	mux.HandleFunc("/api/embed", NotImplementedHandler)
-	mux.HandleFunc("/api/embeddings", NotImplementedHandler)
+	api.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetModelsHandler(w, r, registry)
+	})
+	api.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ChatHandler(w, r, cfg, registry)
+	})
+	api.HandleFunc("/api/generate", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GenerateHandler(w, r, cfg, registry)
+	})
+	api.HandleFunc("/api/pull", func(w http.ResponseWriter, r *http.Request) {
+		handlers.PullHandler(w, r, cfg, registry)
+	})
+	api.HandleFunc("/api/push", NotImplementedHandler)
+	api.HandleFunc("/api/create", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateHandler(w, r, registry)
+	})
+	api.HandleFunc("/api/ps", func(w http.ResponseWriter, r *http.Request) {
+		handlers.PsHandler(w, r, cfg, registry)
+	})
+	api.HandleFunc("/api/copy", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CopyHandler(w, r, registry)
+	})
+	api.HandleFunc("/api/delete", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteHandler(w, r, registry)
+	})
+	api.HandleFunc("/api/show", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ShowHandler(w, r, cfg, registry)
+	})
+	api.HandleFunc("/api/embed", func(w http.ResponseWriter, r *http.Request) {
+		handlers.EmbedHandler(w, r, registry)
+	})
+	api.HandleFunc("/api/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		handlers.EmbeddingsHandler(w, r, registry)
+	})
+	// OpenAI-compatible endpoints, as served by Ollama itself
+	api.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		handlers.OpenAIHandler(w, r, registry)
+	})
+	api.HandleFunc("/v1/completions", func(w http.ResponseWriter, r *http.Request) {
+		handlers.OpenAIHandler(w, r, registry)
+	})
+	api.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		handlers.OpenAIHandler(w, r, registry)
+	})
+	api.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		handlers.OpenAIModelsHandler(w, r, registry)
+	})
+	mux.Handle("/api/", middleware.AuthMiddleware(cfg, api))
+	mux.Handle("/v1/", middleware.AuthMiddleware(cfg, api))
+	if cfg.Metrics {
+		mux.Handle("/debug/vars", middleware.AuthMiddleware(cfg, http.HandlerFunc(handlers.MetricsHandler)))
+	}
+	log.Printf("Authentication mode: %s", cfg.AuthMode)
 
 	loggedMux := middleware.LoggingMiddleware(mux)
 
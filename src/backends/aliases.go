@@ -0,0 +1,218 @@
+package backends
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// Alias is a virtual model, see config.AliasConfig.
+type Alias struct {
+	Name     string
+	Model    string
+	System   string
+	Options  map[string]interface{}
+	Format   json.RawMessage
+	Template string
+	Messages []config.AliasMessage
+	// Created is when the alias was defined.
+	Created time.Time
+
+	configured bool // Defined in the configuration file rather than created at runtime
+}
+
+// ErrConfiguredModel is returned when a model of the configuration file would
+// be replaced at runtime.
+var ErrConfiguredModel = errors.New("model is defined in the configuration file")
+
+// storedAlias is an alias created at runtime as kept in the models file.
+type storedAlias struct {
+	config.AliasConfig
+	Created time.Time `json:"created_at"`
+}
+
+func newAlias(aliasCfg config.AliasConfig, created time.Time) *Alias {
+	return &Alias{
+		Name:     config.TaggedName(aliasCfg.Name),
+		Model:    aliasCfg.Model,
+		System:   aliasCfg.System,
+		Options:  aliasCfg.Options,
+		Format:   aliasCfg.Format,
+		Template: aliasCfg.Template,
+		Messages: aliasCfg.Messages,
+		Created:  created,
+	}
+}
+
+// Configured reports whether the alias comes from the configuration file.
+func (a *Alias) Configured() bool {
+	return a.configured
+}
+
+// Config returns the definition of the alias.
+func (a *Alias) Config() config.AliasConfig {
+	return config.AliasConfig{
+		Name:     a.Name,
+		Model:    a.Model,
+		System:   a.System,
+		Options:  a.Options,
+		Format:   a.Format,
+		Template: a.Template,
+		Messages: a.Messages,
+	}
+}
+
+// Alias returns the alias called name. A name without tag is looked up with
+// the tag ":latest".
+func (r *Registry) Alias(name string) (*Alias, bool) {
+	r.aliasMu.RLock()
+	defer r.aliasMu.RUnlock()
+	alias, ok := r.aliases[config.TaggedName(name)]
+	return alias, ok
+}
+
+// Aliases returns the aliases sorted by name.
+func (r *Registry) Aliases() []*Alias {
+	r.aliasMu.RLock()
+	defer r.aliasMu.RUnlock()
+	aliases := make([]*Alias, 0, len(r.aliases))
+	for _, alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases
+}
+
+// CreateAlias defines an alias at runtime, replacing one created earlier of
+// the same name, and saves it to the models file. Its model is used as is:
+// unlike in the configuration file it may name another alias, whose defaults
+// the caller is expected to have copied.
+func (r *Registry) CreateAlias(aliasCfg config.AliasConfig) (*Alias, error) {
+	alias := newAlias(aliasCfg, time.Now())
+	if _, ok := r.groupByName[alias.Name]; ok {
+		return nil, fmt.Errorf("%w: %s is a model group", ErrConfiguredModel, alias.Name)
+	}
+	if _, err := r.resolveModel(alias.Model); err != nil {
+		return nil, err
+	}
+
+	r.aliasMu.Lock()
+	defer r.aliasMu.Unlock()
+	previous, exists := r.aliases[alias.Name]
+	if exists && previous.configured {
+		return nil, fmt.Errorf("%w: %s", ErrConfiguredModel, alias.Name)
+	}
+	r.aliases[alias.Name] = alias
+	if err := r.saveModelsFile(); err != nil {
+		if exists {
+			r.aliases[alias.Name] = previous
+		} else {
+			delete(r.aliases, alias.Name)
+		}
+		return nil, err
+	}
+	return alias, nil
+}
+
+// DeleteAlias removes an alias created at runtime and saves the models file.
+func (r *Registry) DeleteAlias(name string) error {
+	name = config.TaggedName(name)
+	r.aliasMu.Lock()
+	defer r.aliasMu.Unlock()
+	alias, ok := r.aliases[name]
+	if !ok {
+		return &ErrUnknownModel{Model: name}
+	}
+	if alias.configured {
+		return fmt.Errorf("%w: %s", ErrConfiguredModel, name)
+	}
+	delete(r.aliases, name)
+	if err := r.saveModelsFile(); err != nil {
+		r.aliases[name] = alias
+		return err
+	}
+	return nil
+}
+
+// loadModelsFile adds the aliases kept in the models file. A missing file
+// means none were created yet. Stored aliases are not checked against the
+// backends, so that they survive a backend being offline or renamed for a
+// while; requests for them fail until it is back.
+func (r *Registry) loadModelsFile() error {
+	if r.modelsFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(r.modelsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("models file: %w", err)
+	}
+	var stored []storedAlias
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("models file %s: %w", r.modelsFile, err)
+	}
+	for _, entry := range stored {
+		alias := newAlias(entry.AliasConfig, entry.Created)
+		if _, ok := r.groupByName[alias.Name]; ok {
+			log.Printf("Ignoring created model %s: the configuration file defines a group of that name", alias.Name)
+			continue
+		}
+		if existing, ok := r.aliases[alias.Name]; ok && existing.configured {
+			log.Printf("Ignoring created model %s: the configuration file defines an alias of that name", alias.Name)
+			continue
+		}
+		r.aliases[alias.Name] = alias
+	}
+	return nil
+}
+
+// saveModelsFile writes the aliases created at runtime to the models file,
+// replacing it at once so that a crash can't leave half of it behind. The
+// caller holds aliasMu.
+func (r *Registry) saveModelsFile() error {
+	if r.modelsFile == "" {
+		return nil
+	}
+	stored := []storedAlias{}
+	for _, alias := range r.aliases {
+		if !alias.configured {
+			stored = append(stored, storedAlias{AliasConfig: alias.Config(), Created: alias.Created})
+		}
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(r.modelsFile)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("saving models file: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".models-*.json")
+	if err != nil {
+		return fmt.Errorf("saving models file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving models file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving models file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.modelsFile); err != nil {
+		return fmt.Errorf("saving models file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package backends
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+)
+
+func TestRegistry_CreateAliasIsKept(t *testing.T) {
+	cfg := config.AppConfig{
+		Backends:   []config.BackendConfig{{Name: "openai", BaseURL: "https://api.openai.com", Models: []string{"gpt-*"}}},
+		Aliases:    []config.AliasConfig{{Name: "reviewer:latest", Model: "gpt-4o"}},
+		ModelsFile: filepath.Join(t.TempDir(), "data", "models.json"),
+	}
+	registry, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	created, err := registry.CreateAlias(config.AliasConfig{
+		Name: "helper", Model: "gpt-4o-mini", System: "Be brief.",
+		Options:  map[string]interface{}{"temperature": 0.3},
+		Messages: []config.AliasMessage{{Role: "user", Content: "Hi"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateAlias: %v", err)
+	}
+	if _, err := registry.CreateAlias(config.AliasConfig{Name: "reviewer", Model: "gpt-4o-mini"}); !errors.Is(err, ErrConfiguredModel) {
+		t.Errorf("Expected configured aliases to be kept, got %v", err)
+	}
+	var unknown *ErrUnknownModel
+	if _, err := registry.CreateAlias(config.AliasConfig{Name: "other", Model: "claude-3"}); !errors.As(err, &unknown) {
+		t.Errorf("Expected an unknown model error, got %v", err)
+	}
+
+	// A restarted proxy knows the created alias, but only that one is stored
+	reloaded, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	alias, ok := reloaded.Alias("helper:latest")
+	if !ok || alias.Model != "gpt-4o-mini" || alias.System != "Be brief." || alias.Options["temperature"] != 0.3 ||
+		len(alias.Messages) != 1 || !alias.Created.Equal(created.Created) || alias.Configured() {
+		t.Errorf("Unexpected reloaded alias: %+v", alias)
+	}
+	if aliases := reloaded.Aliases(); len(aliases) != 2 {
+		t.Errorf("Expected the configured and the created alias, got %+v", aliases)
+	}
+	if _, err := os.Stat(cfg.ModelsFile); err != nil {
+		t.Errorf("Expected the models file to exist: %v", err)
+	}
+
+	// Deleting is kept as well
+	if err := reloaded.DeleteAlias("helper"); err != nil {
+		t.Fatalf("DeleteAlias: %v", err)
+	}
+	if err := reloaded.DeleteAlias("reviewer"); !errors.Is(err, ErrConfiguredModel) {
+		t.Errorf("Expected configured aliases to be kept, got %v", err)
+	}
+	if reloaded, err = NewRegistry(cfg); err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if _, ok := reloaded.Alias("helper"); ok {
+		t.Error("Expected the deleted alias to stay deleted")
+	}
+}
+
+func TestRegistry_InvalidModelsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewRegistry(config.AppConfig{
+		Backends:   []config.BackendConfig{{Name: "openai", BaseURL: "https://api.openai.com"}},
+		ModelsFile: path,
+	})
+	if err == nil {
+		t.Error("Expected an error for a corrupt models file")
+	}
+}
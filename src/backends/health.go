@@ -0,0 +1,68 @@
+package backends
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// health passively tracks the outcome of requests to a backend. After
+// maxFailures consecutive failures the backend is ejected for cooldown; once
+// that has passed it is tried again, and a single further failure ejects it
+// anew until a request succeeds.
+type health struct {
+	maxFailures int
+	cooldown    time.Duration
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+
+	inflight int64
+}
+
+// Healthy reports whether the backend is in rotation. An ejected backend
+// becomes healthy again when its cool-down has passed.
+func (b *Backend) Healthy() bool {
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+	return !time.Now().Before(b.health.ejectedUntil)
+}
+
+// RecordSuccess resets the failure count of the backend.
+func (b *Backend) RecordSuccess() {
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+	if b.health.failures >= b.health.maxFailures && b.health.maxFailures > 0 {
+		log.Printf("Backend %s recovered", b.Name)
+	}
+	b.health.failures = 0
+	b.health.ejectedUntil = time.Time{}
+}
+
+// RecordFailure counts a connection error or 5xx response and ejects the
+// backend once it failed too often in a row.
+func (b *Backend) RecordFailure() {
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+	b.health.failures++
+	if b.health.maxFailures > 0 && b.health.failures >= b.health.maxFailures {
+		b.health.ejectedUntil = time.Now().Add(b.health.cooldown)
+		log.Printf("Backend %s failed %d times in a row, ejected for %s", b.Name, b.health.failures, b.health.cooldown)
+	}
+}
+
+// Begin counts a request as in flight until the returned function is called.
+func (b *Backend) Begin() (done func()) {
+	atomic.AddInt64(&b.health.inflight, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt64(&b.health.inflight, -1) })
+	}
+}
+
+// Inflight returns the number of open requests to the backend.
+func (b *Backend) Inflight() int64 {
+	return atomic.LoadInt64(&b.health.inflight)
+}
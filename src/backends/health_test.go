@@ -0,0 +1,117 @@
+package backends
+
+import (
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// newGroupRegistry has a group "gpt-4o" with the members a, b and c.
+func newGroupRegistry(t *testing.T, strategy string, health config.HealthConfig, weights ...int) *Registry {
+	t.Helper()
+	group := config.GroupConfig{Name: "gpt-4o", Strategy: strategy}
+	var backendCfgs []config.BackendConfig
+	for i, name := range []string{"a", "b", "c"} {
+		backendCfgs = append(backendCfgs, config.BackendConfig{Name: name, BaseURL: "http://" + name})
+		member := config.MemberConfig{Backend: name, Model: "gpt-4o-" + name}
+		if i < len(weights) {
+			member.Weight = weights[i]
+		}
+		group.Members = append(group.Members, member)
+	}
+	registry, err := NewRegistry(config.AppConfig{Backends: backendCfgs, Groups: []config.GroupConfig{group}, Health: health})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return registry
+}
+
+// order resolves the group and returns the backend names in the order tried.
+func order(t *testing.T, registry *Registry) string {
+	t.Helper()
+	targets, err := registry.Resolve("gpt-4o")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	names := ""
+	for _, target := range targets {
+		if target.Model != "gpt-4o-"+target.Backend.Name {
+			t.Errorf("Unexpected model %q for backend %s", target.Model, target.Backend.Name)
+		}
+		names += target.Backend.Name
+	}
+	return names
+}
+
+func TestGroup_Strategies(t *testing.T) {
+	registry := newGroupRegistry(t, config.StrategyFailover, config.HealthConfig{})
+	for i := 0; i < 3; i++ {
+		if got := order(t, registry); got != "abc" {
+			t.Errorf("Failover order %q, want abc", got)
+		}
+	}
+
+	registry = newGroupRegistry(t, config.StrategyRoundRobin, config.HealthConfig{})
+	for _, want := range []string{"abc", "bca", "cab", "abc"} {
+		if got := order(t, registry); got != want {
+			t.Errorf("Round robin order %q, want %q", got, want)
+		}
+	}
+
+	registry = newGroupRegistry(t, config.StrategyLeastInflight, config.HealthConfig{})
+	doneA := registry.Backends()[0].Begin()
+	registry.Backends()[0].Begin()
+	doneB := registry.Backends()[1].Begin()
+	if got := order(t, registry); got != "cba" {
+		t.Errorf("Least inflight order %q, want cba", got)
+	}
+	doneA()
+	doneA() // Ending twice only counts once
+	doneB()
+	if got := order(t, registry); got != "bca" {
+		t.Errorf("Least inflight order %q, want bca", got)
+	}
+}
+
+func TestGroup_Weighted(t *testing.T) {
+	registry := newGroupRegistry(t, config.StrategyWeighted, config.HealthConfig{}, 8, 1, 1)
+	first := make(map[byte]int)
+	for i := 0; i < 1000; i++ {
+		got := order(t, registry)
+		if len(got) != 3 {
+			t.Fatalf("Expected every member once, got %q", got)
+		}
+		first[got[0]]++
+	}
+	if first['a'] < 700 || first['b'] == 0 || first['c'] == 0 {
+		t.Errorf("Unexpected distribution of first choices: %v", first)
+	}
+}
+
+func TestBackend_HealthTracking(t *testing.T) {
+	registry := newGroupRegistry(t, config.StrategyFailover, config.HealthConfig{MaxFailures: 2, Cooldown: config.Duration(50 * time.Millisecond)})
+	a := registry.Backends()[0]
+
+	a.RecordFailure()
+	if got := order(t, registry); got != "abc" {
+		t.Errorf("A single failure must not eject, got %q", got)
+	}
+	a.RecordFailure()
+	if got := order(t, registry); got != "bca" {
+		t.Errorf("Expected the ejected backend last, got %q", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := order(t, registry); got != "abc" {
+		t.Errorf("Expected the backend back after the cool-down, got %q", got)
+	}
+	a.RecordFailure() // The probe fails
+	if a.Healthy() {
+		t.Error("Expected a failed probe to eject the backend again")
+	}
+	a.RecordSuccess()
+	if !a.Healthy() {
+		t.Error("Expected a success to restore the backend")
+	}
+}
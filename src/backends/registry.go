@@ -0,0 +1,326 @@
+// Package backends routes requests to the upstream API serving the requested model.
+package backends
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/upstream"
+)
+
+// Backend is an upstream API with its own credentials and client.
+type Backend struct {
+	Name    string
+	BaseURL string
+	// Type is the API the backend speaks, see config.BackendConfig.Type.
+	Type      string
+	MaxTokens int
+	APIKey    string
+	// Models are exact names or glob patterns; empty means any model.
+	Models []string
+	// APIVersion and Deployments are only used by Azure OpenAI backends.
+	APIVersion  string
+	Deployments map[string]string
+	Client      *upstream.Client
+
+	health health
+
+	enabledMu sync.RWMutex
+	enabled   map[string]bool // Models enabled at runtime, see Enable
+}
+
+// Target is a backend together with the model name it knows a model by.
+type Target struct {
+	Backend *Backend
+	Model   string
+}
+
+// Group is a logical model served by several targets, see config.GroupConfig.
+type Group struct {
+	Name     string
+	Strategy string
+	Members  []Target
+	weights  []int
+	next     uint64 // Round robin position
+}
+
+// Authorization returns the Authorization header to send to the backend. Its
+// own key wins over clientAuth, the header of the incoming request (which the
+// auth middleware may already have replaced with OPENAI_API_KEY).
+func (b *Backend) Authorization(clientAuth string) string {
+	if b.APIKey != "" {
+		return "Bearer " + b.APIKey
+	}
+	return clientAuth
+}
+
+// Serves reports whether model is one of the backend's models.
+func (b *Backend) Serves(model string) bool {
+	if len(b.Models) == 0 {
+		return true
+	}
+	for _, pattern := range b.Models {
+		if matched, err := path.Match(pattern, model); err == nil && matched {
+			return true
+		}
+	}
+	b.enabledMu.RLock()
+	defer b.enabledMu.RUnlock()
+	return b.enabled[model]
+}
+
+// Enable adds model to the backend's models until the proxy restarts.
+func (b *Backend) Enable(model string) {
+	b.enabledMu.Lock()
+	defer b.enabledMu.Unlock()
+	if b.enabled == nil {
+		b.enabled = make(map[string]bool)
+	}
+	b.enabled[model] = true
+}
+
+// Deployment returns the name of the Azure OpenAI deployment serving model.
+func (b *Backend) Deployment(model string) string {
+	if deployment, ok := b.Deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
+// ErrUnknownModel is returned for models no backend serves.
+type ErrUnknownModel struct {
+	Model string
+}
+
+func (e *ErrUnknownModel) Error() string {
+	return fmt.Sprintf("model %q not found", e.Model)
+}
+
+// Registry holds the configured backends and model groups in configuration order.
+type Registry struct {
+	backends     []*Backend
+	byName       map[string]*Backend
+	groups       []*Group
+	groupByName  map[string]*Group
+	prefixModels bool
+
+	aliasMu    sync.RWMutex
+	aliases    map[string]*Alias // By tagged name
+	modelsFile string
+
+	usageMu   sync.Mutex
+	usage     map[string]*modelUsage // By requested name
+	keepAlive time.Duration
+}
+
+// NewRegistry creates a client for each backend in cfg.
+func NewRegistry(cfg config.AppConfig) (*Registry, error) {
+	registry := &Registry{
+		byName:       make(map[string]*Backend),
+		groupByName:  make(map[string]*Group),
+		prefixModels: cfg.PrefixModels,
+		aliases:      make(map[string]*Alias),
+		modelsFile:   cfg.ModelsFile,
+		usage:        make(map[string]*modelUsage),
+		keepAlive:    cfg.KeepAlive,
+	}
+	for _, backendCfg := range cfg.Backends {
+		client, err := upstream.NewClient(backendCfg.Upstream)
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %w", backendCfg.Name, err)
+		}
+		backend := &Backend{
+			Name:        backendCfg.Name,
+			BaseURL:     strings.TrimSuffix(backendCfg.BaseURL, "/"),
+			Type:        backendCfg.Type,
+			MaxTokens:   backendCfg.MaxTokens,
+			APIKey:      backendCfg.APIKey,
+			Models:      backendCfg.Models,
+			APIVersion:  backendCfg.APIVersion,
+			Deployments: backendCfg.Deployments,
+			Client:      client,
+			health: health{
+				maxFailures: cfg.Health.MaxFailures,
+				cooldown:    time.Duration(cfg.Health.Cooldown),
+			},
+		}
+		registry.backends = append(registry.backends, backend)
+		registry.byName[backend.Name] = backend
+	}
+
+	for _, groupCfg := range cfg.Groups {
+		if len(groupCfg.Members) == 0 {
+			return nil, fmt.Errorf("group %s has no members", groupCfg.Name)
+		}
+		group := &Group{Name: groupCfg.Name, Strategy: groupCfg.Strategy}
+		for _, member := range groupCfg.Members {
+			backend, ok := registry.byName[member.Backend]
+			if !ok {
+				return nil, fmt.Errorf("group %s: unknown backend %q", groupCfg.Name, member.Backend)
+			}
+			weight := member.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			group.Members = append(group.Members, Target{Backend: backend, Model: member.Model})
+			group.weights = append(group.weights, weight)
+		}
+		registry.groups = append(registry.groups, group)
+		registry.groupByName[group.Name] = group
+	}
+
+	now := time.Now()
+	for _, aliasCfg := range cfg.Aliases {
+		if _, err := registry.resolveModel(aliasCfg.Model); err != nil {
+			return nil, fmt.Errorf("alias %s: %w", aliasCfg.Name, err)
+		}
+		alias := newAlias(aliasCfg, now)
+		alias.configured = true
+		registry.aliases[alias.Name] = alias
+	}
+	if err := registry.loadModelsFile(); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Backends returns the backends in configuration order.
+func (r *Registry) Backends() []*Backend {
+	return r.backends
+}
+
+// Groups returns the model groups in configuration order.
+func (r *Registry) Groups() []*Group {
+	return r.groups
+}
+
+// PrefixModels reports whether model names are listed as backend/model.
+func (r *Registry) PrefixModels() bool {
+	return r.prefixModels
+}
+
+// Resolve returns the targets to try for model, in order. An alias is
+// replaced by its model first. A group yields all its members, ordered by the
+// group's strategy with ejected backends last, so callers can fail over from
+// one to the next. Any other model has a single target.
+//
+// A model written as backend/model always goes to the named backend, with the
+// prefix removed. Otherwise the first backend whose models match is used. As
+// upstream model names may contain slashes themselves (e.g. on OpenRouter),
+// the prefix is only tried first when prefixing is enabled.
+func (r *Registry) Resolve(model string) ([]Target, error) {
+	if alias, ok := r.Alias(model); ok {
+		model = alias.Model
+	}
+	return r.resolveModel(model)
+}
+
+// Targets returns all targets that can serve model, like Resolve but in
+// configuration order and without counting a request towards round robin.
+func (r *Registry) Targets(model string) ([]Target, error) {
+	if alias, ok := r.Alias(model); ok {
+		model = alias.Model
+	}
+	if group, ok := r.groupByName[model]; ok {
+		return append([]Target(nil), group.Members...), nil
+	}
+	return r.resolveModel(model)
+}
+
+// resolveModel is Resolve for a model that isn't an alias.
+func (r *Registry) resolveModel(model string) ([]Target, error) {
+	if group, ok := r.groupByName[model]; ok {
+		return group.order(), nil
+	}
+	if r.prefixModels {
+		if backend, name, ok := r.resolvePrefixed(model); ok {
+			return []Target{{Backend: backend, Model: name}}, nil
+		}
+	}
+	for _, backend := range r.backends {
+		if backend.Serves(model) {
+			return []Target{{Backend: backend, Model: model}}, nil
+		}
+	}
+	if !r.prefixModels {
+		if backend, name, ok := r.resolvePrefixed(model); ok {
+			return []Target{{Backend: backend, Model: name}}, nil
+		}
+	}
+	return nil, &ErrUnknownModel{Model: model}
+}
+
+// resolvePrefixed splits a backend/model name.
+func (r *Registry) resolvePrefixed(model string) (*Backend, string, bool) {
+	name, upstreamModel, found := strings.Cut(model, "/")
+	if !found || upstreamModel == "" {
+		return nil, "", false
+	}
+	backend, ok := r.byName[name]
+	if !ok || !backend.Serves(upstreamModel) {
+		return nil, "", false
+	}
+	return backend, upstreamModel, true
+}
+
+// order returns the members in the order they should be tried. Ejected
+// backends are kept as a last resort, so a group whose members all failed
+// recently still gets a chance to answer.
+func (g *Group) order() []Target {
+	var ordered []Target
+	switch g.Strategy {
+	case config.StrategyRoundRobin:
+		start := int((atomic.AddUint64(&g.next, 1) - 1) % uint64(len(g.Members)))
+		ordered = append(append(ordered, g.Members[start:]...), g.Members[:start]...)
+	case config.StrategyLeastInflight:
+		ordered = append(ordered, g.Members...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return ordered[i].Backend.Inflight() < ordered[j].Backend.Inflight()
+		})
+	case config.StrategyWeighted:
+		ordered = weightedOrder(g.Members, g.weights)
+	default: // config.StrategyFailover
+		ordered = append(ordered, g.Members...)
+	}
+
+	healthy := make([]Target, 0, len(ordered))
+	var ejected []Target
+	for _, target := range ordered {
+		if target.Backend.Healthy() {
+			healthy = append(healthy, target)
+		} else {
+			ejected = append(ejected, target)
+		}
+	}
+	return append(healthy, ejected...)
+}
+
+// weightedOrder draws all members at random, each draw in proportion to the
+// weights of the members left.
+func weightedOrder(members []Target, weights []int) []Target {
+	remaining := append([]Target(nil), members...)
+	remainingWeights := append([]int(nil), weights...)
+	ordered := make([]Target, 0, len(members))
+	for len(remaining) > 0 {
+		total := 0
+		for _, weight := range remainingWeights {
+			total += weight
+		}
+		pick, n := 0, rand.Intn(total)
+		for n >= remainingWeights[pick] {
+			n -= remainingWeights[pick]
+			pick++
+		}
+		ordered = append(ordered, remaining[pick])
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		remainingWeights = append(remainingWeights[:pick], remainingWeights[pick+1:]...)
+	}
+	return ordered
+}
@@ -0,0 +1,118 @@
+package backends
+
+import (
+	"errors"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+)
+
+func newTestRegistry(t *testing.T, prefixModels bool) *Registry {
+	t.Helper()
+	registry, err := NewRegistry(config.AppConfig{
+		PrefixModels: prefixModels,
+		Backends: []config.BackendConfig{
+			{Name: "vllm", BaseURL: "http://vllm:8000/", APIKey: "sk-vllm", Models: []string{"llama-*", "qwen3"}},
+			{Name: "openrouter", BaseURL: "https://openrouter.ai/api", Models: []string{"*/*"}},
+			{Name: "openai", BaseURL: "https://api.openai.com"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return registry
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	tests := []struct {
+		prefixModels bool
+		model        string
+		wantBackend  string
+		wantModel    string
+	}{
+		{false, "llama-3.1-8b", "vllm", "llama-3.1-8b"},
+		{false, "qwen3", "vllm", "qwen3"},
+		{false, "anthropic/claude-3.5-sonnet", "openrouter", "anthropic/claude-3.5-sonnet"},
+		{false, "gpt-4o", "openai", "gpt-4o"},
+		// Slashes belong to the model name unless prefixing is enabled
+		{false, "vllm/qwen3", "openrouter", "vllm/qwen3"},
+		{true, "vllm/qwen3", "vllm", "qwen3"},
+		{true, "openai/gpt-4o", "openai", "gpt-4o"},
+		{true, "openrouter/anthropic/claude-3.5-sonnet", "openrouter", "anthropic/claude-3.5-sonnet"},
+		// Unprefixed names keep working with prefixing enabled
+		{true, "llama-3.1-8b", "vllm", "llama-3.1-8b"},
+		// vllm doesn't serve gpt-4o, so the name is routed by pattern instead
+		{true, "vllm/gpt-4o", "openrouter", "vllm/gpt-4o"},
+	}
+	for _, tt := range tests {
+		targets, err := newTestRegistry(t, tt.prefixModels).Resolve(tt.model)
+		if err != nil || len(targets) != 1 {
+			t.Errorf("Resolve(%q) = %v, %v; want a single target", tt.model, targets, err)
+			continue
+		}
+		if targets[0].Backend.Name != tt.wantBackend || targets[0].Model != tt.wantModel {
+			t.Errorf("Resolve(%q) with prefixing %v = %s, %q; want %s, %q", tt.model, tt.prefixModels, targets[0].Backend.Name, targets[0].Model, tt.wantBackend, tt.wantModel)
+		}
+	}
+}
+
+func TestRegistry_ResolveUnknownModel(t *testing.T) {
+	registry, err := NewRegistry(config.AppConfig{Backends: []config.BackendConfig{
+		{Name: "openai", BaseURL: "https://api.openai.com", Models: []string{"gpt-4o"}},
+	}})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	_, err = registry.Resolve("gpt-3.5-turbo")
+	var unknown *ErrUnknownModel
+	if !errors.As(err, &unknown) || unknown.Model != "gpt-3.5-turbo" {
+		t.Errorf("Expected ErrUnknownModel, got %v", err)
+	}
+}
+
+func TestRegistry_ResolveAlias(t *testing.T) {
+	registry, err := NewRegistry(config.AppConfig{
+		Backends: []config.BackendConfig{{Name: "openai", BaseURL: "https://api.openai.com", Models: []string{"gpt-*"}}},
+		Aliases: []config.AliasConfig{
+			{Name: "reviewer:latest", Model: "gpt-4o", System: "Review code."},
+			{Name: "gpt-4o-mini:latest", Model: "gpt-4o-mini"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	for model, want := range map[string]string{"reviewer": "gpt-4o", "reviewer:latest": "gpt-4o", "gpt-4o-mini": "gpt-4o-mini"} {
+		targets, err := registry.Resolve(model)
+		if err != nil || len(targets) != 1 || targets[0].Model != want {
+			t.Errorf("Resolve(%q) = %v, %v; want %q", model, targets, err, want)
+		}
+	}
+	if alias, ok := registry.Alias("reviewer"); !ok || alias.Name != "reviewer:latest" || alias.System != "Review code." {
+		t.Errorf("Alias(reviewer) = %+v, %v", alias, ok)
+	}
+	if aliases := registry.Aliases(); len(aliases) != 2 || aliases[0].Name != "gpt-4o-mini:latest" {
+		t.Errorf("Expected the aliases sorted by name, got %+v", aliases)
+	}
+
+	_, err = NewRegistry(config.AppConfig{
+		Backends: []config.BackendConfig{{Name: "openai", BaseURL: "https://api.openai.com", Models: []string{"gpt-*"}}},
+		Aliases:  []config.AliasConfig{{Name: "helper:latest", Model: "claude-3"}},
+	})
+	if err == nil {
+		t.Error("Expected an error for an alias of a model no backend serves")
+	}
+}
+
+func TestBackend_Authorization(t *testing.T) {
+	registry := newTestRegistry(t, false)
+	vllm, openai := registry.Backends()[0], registry.Backends()[2]
+	if got := vllm.Authorization("Bearer client"); got != "Bearer sk-vllm" {
+		t.Errorf("Expected the backend key to win, got %q", got)
+	}
+	if got := openai.Authorization("Bearer client"); got != "Bearer client" {
+		t.Errorf("Expected the client's header, got %q", got)
+	}
+	if vllm.BaseURL != "http://vllm:8000" {
+		t.Errorf("Expected the trailing slash to be trimmed, got %q", vllm.BaseURL)
+	}
+}
@@ -0,0 +1,140 @@
+package backends
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModelUsage is a model that is being served or was used recently, see
+// Registry.Running.
+type ModelUsage struct {
+	Name      string
+	InFlight  int
+	LastUsed  time.Time
+	ExpiresAt time.Time
+}
+
+// modelUsage tracks the requests for a model.
+type modelUsage struct {
+	inFlight  int
+	accepted  bool          // Whether the upstream ever accepted a request for the model
+	lastUsed  time.Time     // Acceptance of the latest request or end of the last one
+	keepAlive time.Duration // Of the latest accepted request
+}
+
+// expiresAt returns when the model stops being listed.
+func (u *modelUsage) expiresAt(now time.Time) time.Time {
+	if u.inFlight > 0 {
+		return now.Add(u.keepAlive)
+	}
+	return u.lastUsed.Add(u.keepAlive)
+}
+
+// ModelRequest is a request counted by Registry.BeginRequest.
+type ModelRequest struct {
+	registry  *Registry
+	usage     *modelUsage
+	keepAlive time.Duration
+	accepted  bool
+	once      sync.Once
+}
+
+// BeginRequest counts a request for model until End is called. The model is
+// only listed by Running once the upstream has accepted a request for it, see
+// Accept. After the request the model stays listed for keepAlive, or the
+// default keep-alive when keepAlive is nil, as Ollama keeps a model loaded. An
+// alias is counted under its tagged name.
+func (r *Registry) BeginRequest(model string, keepAlive *time.Duration) *ModelRequest {
+	if alias, ok := r.Alias(model); ok {
+		model = alias.Name
+	}
+	request := &ModelRequest{registry: r, keepAlive: r.keepAlive}
+	if keepAlive != nil {
+		request.keepAlive = *keepAlive
+	}
+
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+	r.pruneUsage(time.Now())
+	usage, ok := r.usage[model]
+	if !ok {
+		usage = &modelUsage{}
+		r.usage[model] = usage
+	}
+	usage.inFlight++
+	request.usage = usage
+	return request
+}
+
+// Accept records that the upstream accepted the request, which makes the
+// model a used one.
+func (m *ModelRequest) Accept() {
+	m.registry.usageMu.Lock()
+	defer m.registry.usageMu.Unlock()
+	m.accepted = true
+	m.usage.accepted = true
+	m.usage.lastUsed = time.Now()
+	m.usage.keepAlive = m.keepAlive
+}
+
+// End ends the request. Ending it again has no effect.
+func (m *ModelRequest) End() {
+	m.once.Do(func() {
+		m.registry.usageMu.Lock()
+		defer m.registry.usageMu.Unlock()
+		m.usage.inFlight--
+		if m.accepted {
+			m.usage.lastUsed = time.Now()
+		}
+		m.registry.pruneUsage(time.Now())
+	})
+}
+
+// Unload ends the keep-alive of model, as a request with a keep-alive of 0
+// does. Requests in flight keep it listed until they end.
+func (r *Registry) Unload(model string) {
+	if alias, ok := r.Alias(model); ok {
+		model = alias.Name
+	}
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+	if usage, ok := r.usage[model]; ok {
+		usage.lastUsed = time.Now()
+		usage.keepAlive = 0
+		r.pruneUsage(time.Now())
+	}
+}
+
+// Running returns the models with accepted requests in flight or whose
+// keep-alive hasn't expired yet, the most recently used first.
+func (r *Registry) Running() []ModelUsage {
+	now := time.Now()
+	r.usageMu.Lock()
+	defer r.usageMu.Unlock()
+	r.pruneUsage(now)
+	var running []ModelUsage
+	for name, usage := range r.usage {
+		if !usage.accepted {
+			continue
+		}
+		running = append(running, ModelUsage{Name: name, InFlight: usage.inFlight, LastUsed: usage.lastUsed, ExpiresAt: usage.expiresAt(now)})
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if !running[i].LastUsed.Equal(running[j].LastUsed) {
+			return running[i].LastUsed.After(running[j].LastUsed)
+		}
+		return running[i].Name < running[j].Name
+	})
+	return running
+}
+
+// pruneUsage forgets the models without requests in flight that were never
+// accepted or whose keep-alive has expired. r.usageMu must be held.
+func (r *Registry) pruneUsage(now time.Time) {
+	for name, usage := range r.usage {
+		if usage.inFlight == 0 && (!usage.accepted || !usage.expiresAt(now).After(now)) {
+			delete(r.usage, name)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package backends
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+func TestRegistry_Running(t *testing.T) {
+	registry, err := NewRegistry(config.AppConfig{
+		Backends:  []config.BackendConfig{{Name: "openai", BaseURL: "https://api.openai.com"}},
+		Aliases:   []config.AliasConfig{{Name: "reviewer:latest", Model: "gpt-4o"}},
+		KeepAlive: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	// accepted counts a request the upstream accepted
+	accepted := func(model string, keepAlive *time.Duration) *ModelRequest {
+		request := registry.BeginRequest(model, keepAlive)
+		request.Accept()
+		return request
+	}
+	chat := accepted("gpt-4o", nil)
+	review := accepted("reviewer", nil)
+	accepted("reviewer:latest", nil).End()
+	none := time.Duration(0)
+	accepted("gpt-4o-mini", &none).End()
+	forever := time.Duration(math.MaxInt64)
+	accepted("o3", &forever).End()
+	pending := registry.BeginRequest("gpt-4.1", nil) // Not accepted yet
+	registry.BeginRequest("made-up", nil).End()      // Rejected
+
+	byName := make(map[string]ModelUsage)
+	for _, usage := range registry.Running() {
+		byName[usage.Name] = usage
+	}
+	if len(byName) != 3 {
+		t.Fatalf("Expected gpt-4o, reviewer:latest and o3, got %+v", byName)
+	}
+	if byName["gpt-4o"].InFlight != 1 || byName["reviewer:latest"].InFlight != 1 {
+		t.Errorf("Expected one request in flight for each, got %+v", byName)
+	}
+	if expires := time.Until(byName["gpt-4o"].ExpiresAt); expires < 59*time.Second || expires > time.Minute {
+		t.Errorf("Expected the default keep-alive, got %v", expires)
+	}
+	if byName["o3"].ExpiresAt.Before(time.Now().AddDate(100, 0, 0)) {
+		t.Errorf("Expected o3 to be kept for good, got %+v", byName["o3"])
+	}
+
+	chat.End()
+	chat.End() // Ending twice counts once
+	review.End()
+	pending.End()
+	if usage := registry.Running()[0]; usage.InFlight != 0 {
+		t.Errorf("Expected no requests in flight, got %+v", usage)
+	}
+	registry.usageMu.Lock()
+	_, remembered := registry.usage["made-up"]
+	tracked := len(registry.usage)
+	registry.usageMu.Unlock()
+	if remembered || tracked != 3 {
+		t.Errorf("Expected models without accepted requests to be forgotten, got %d models", tracked)
+	}
+
+	// Unloading ends the listing once no request is in flight
+	registry.Unload("gpt-4o")
+	for _, usage := range registry.Running() {
+		if usage.Name == "gpt-4o" {
+			t.Errorf("Expected gpt-4o to have expired, got %+v", usage)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backend types, see BackendConfig.Type.
+const (
+	// BackendTypeOpenAI is an OpenAI-compatible API.
+	BackendTypeOpenAI = "openai"
+	// BackendTypeAnthropic is Anthropic's Messages API.
+	BackendTypeAnthropic = "anthropic"
+	// BackendTypeGemini is Google's Gemini API (generateContent).
+	BackendTypeGemini = "gemini"
+	// BackendTypeAzure is Azure OpenAI, which serves models from deployments.
+	BackendTypeAzure = "azure"
+)
+
+// BackendConfig describes an upstream API the proxy routes requests to.
+type BackendConfig struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"base_url"`
+	// Type is the API the backend speaks, "openai" unless set.
+	Type string `json:"type,omitempty"`
+	// MaxTokens is sent when num_predict isn't set, to APIs that require a
+	// limit such as Anthropic's. Defaults to 4096.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// The backend's own key comes from api_key, the variable named by
+	// api_key_env or the file api_key_file. Without one, the key sent by the
+	// client (passthrough mode) or OPENAI_API_KEY is used.
+	APIKey     string `json:"api_key,omitempty"`
+	APIKeyEnv  string `json:"api_key_env,omitempty"`
+	APIKeyFile string `json:"api_key_file,omitempty"`
+	// Models lists the models served by the backend, as exact names or glob
+	// patterns such as gpt-*. A backend without models serves any model.
+	Models []string `json:"models,omitempty"`
+	// APIVersion is the api-version sent to Azure OpenAI. Defaults to
+	// 2024-10-21.
+	APIVersion string `json:"api_version,omitempty"`
+	// Deployments maps model names to the Azure OpenAI deployments serving
+	// them. Other models are sent to the deployment of the same name. Models
+	// defaults to the mapped names.
+	Deployments map[string]string `json:"deployments,omitempty"`
+
+	// Overrides of the UPSTREAM_* client settings for this backend.
+	ConnectTimeout        *Duration `json:"connect_timeout,omitempty"`
+	ResponseHeaderTimeout *Duration `json:"response_header_timeout,omitempty"`
+	Timeout               *Duration `json:"timeout,omitempty"`
+	StreamTimeout         *Duration `json:"stream_timeout,omitempty"`
+	MaxRetries            *int      `json:"max_retries,omitempty"`
+
+	// Upstream is the client configuration with the overrides applied.
+	Upstream UpstreamConfig `json:"-"`
+}
+
+// Duration is a time.Duration written as a string such as "30s" in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Load balancing strategies of a model group, see GroupConfig.Strategy.
+const (
+	// StrategyFailover tries the members in configuration order.
+	StrategyFailover = "failover"
+	// StrategyRoundRobin rotates the first member tried on every request.
+	StrategyRoundRobin = "round_robin"
+	// StrategyLeastInflight prefers the member with the fewest open requests.
+	StrategyLeastInflight = "least_inflight"
+	// StrategyWeighted picks members at random in proportion to their weight.
+	StrategyWeighted = "weighted"
+)
+
+// GroupConfig is a logical model served by several equivalent backends or
+// deployments. Requests for Name are balanced across the members according to
+// Strategy and fail over to the next member on connection errors and 5xx
+// responses.
+type GroupConfig struct {
+	Name     string         `json:"name"`
+	Strategy string         `json:"strategy,omitempty"`
+	Members  []MemberConfig `json:"members"`
+}
+
+// MemberConfig is a backend serving a group, with the model name it uses.
+type MemberConfig struct {
+	Backend string `json:"backend"`
+	// Model defaults to the group name.
+	Model string `json:"model,omitempty"`
+	// Weight is only used by the weighted strategy and defaults to 1.
+	Weight int `json:"weight,omitempty"`
+}
+
+// AliasConfig is a virtual model: an upstream model, group or other model
+// name together with defaults for its requests, like an Ollama Modelfile
+// without weights.
+type AliasConfig struct {
+	// Name is listed in /api/tags. Without a tag ":latest" is added, as
+	// Ollama does.
+	Name  string `json:"name"`
+	Model string `json:"model"`
+	// System is used when a request brings no system prompt of its own.
+	System string `json:"system,omitempty"`
+	// Options are Ollama options that requests can override one by one.
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Format is used when a request sets no format.
+	Format json.RawMessage `json:"format,omitempty"`
+	// Template is kept for /api/show; upstream APIs apply their own.
+	Template string `json:"template,omitempty"`
+	// Messages start every chat, before the messages of the request.
+	Messages []AliasMessage `json:"messages,omitempty"`
+}
+
+// AliasMessage is a message of an alias's conversation.
+type AliasMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ValidMessageRole reports whether role can start a conversation.
+func ValidMessageRole(role string) bool {
+	return role == "system" || role == "user" || role == "assistant"
+}
+
+// Model capabilities as reported by /api/show.
+const (
+	CapabilityCompletion = "completion"
+	CapabilityVision     = "vision"
+	CapabilityTools      = "tools"
+	CapabilityThinking   = "thinking"
+	CapabilityEmbedding  = "embedding"
+)
+
+// MetadataConfig describes models for /api/show. OpenAI-compatible APIs don't
+// report context lengths or capabilities, so they can be configured here.
+type MetadataConfig struct {
+	// Models are exact names or glob patterns, matched against the requested
+	// name as well as the upstream model name.
+	Models          []string `json:"models"`
+	ContextLength   int      `json:"context_length,omitempty"`
+	EmbeddingLength int      `json:"embedding_length,omitempty"`
+	// Capabilities replace the ones derived from TEXT_ONLY_MODELS,
+	// REASONING_MODELS and the model name.
+	Capabilities      []string `json:"capabilities,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
+}
+
+// TaggedName returns an Ollama model name with the tag ":latest" if it has
+// none.
+func TaggedName(name string) string {
+	if strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return name
+	}
+	return name + ":latest"
+}
+
+// HealthConfig controls passive health tracking: a backend failing MaxFailures
+// requests in a row is skipped for Cooldown, after which it gets another try.
+// A MaxFailures of 0 disables ejection.
+type HealthConfig struct {
+	MaxFailures int      `json:"max_failures"`
+	Cooldown    Duration `json:"cooldown"`
+}
+
+// defaultMaxTokens is the output limit sent to APIs that require one.
+const defaultMaxTokens = 4096
+
+// defaultAzureAPIVersion is the Azure OpenAI api-version used unless a backend
+// sets one.
+const defaultAzureAPIVersion = "2024-10-21"
+
+// defaultHealth is used when the file doesn't configure health tracking.
+var defaultHealth = HealthConfig{MaxFailures: 3, Cooldown: Duration(30 * time.Second)}
+
+// fileConfig is the structure of the file named by PROXY_CONFIG_FILE.
+type fileConfig struct {
+	// PrefixModels lists models in /api/tags as backend/model.
+	PrefixModels bool             `json:"prefix_models"`
+	Backends     []BackendConfig  `json:"backends"`
+	Groups       []GroupConfig    `json:"groups,omitempty"`
+	Aliases      []AliasConfig    `json:"aliases,omitempty"`
+	Metadata     []MetadataConfig `json:"metadata,omitempty"`
+	Health       *HealthConfig    `json:"health,omitempty"`
+}
+
+// loadBackends reads the backends, model groups, aliases and metadata from
+// PROXY_CONFIG_FILE.
+// Without a file the single backend described by OPENAI_API_BASE_URL and
+// OPENAI_ALLOWED_MODELS is used.
+func loadBackends(baseURL string, allowedModels []string, upstream UpstreamConfig) (fileConfig, error) {
+	path := os.Getenv("PROXY_CONFIG_FILE")
+	if path == "" {
+		return fileConfig{
+			Backends: []BackendConfig{{
+				Name:      "openai",
+				BaseURL:   baseURL,
+				Type:      BackendTypeOpenAI,
+				MaxTokens: defaultMaxTokens,
+				Models:    allowedModels,
+				Upstream:  upstream,
+			}},
+			Health: &defaultHealth,
+		}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("reading PROXY_CONFIG_FILE: %w", err)
+	}
+	health := defaultHealth // Settings missing from the file keep their default
+	file := fileConfig{Health: &health}
+	decoder := json.NewDecoder(strings.NewReader(string(content)))
+	decoder.DisallowUnknownFields() // Catch typos in option names
+	if err := decoder.Decode(&file); err != nil {
+		return fileConfig{}, fmt.Errorf("parsing PROXY_CONFIG_FILE %s: %w", path, err)
+	}
+	if len(file.Backends) == 0 {
+		return fileConfig{}, fmt.Errorf("PROXY_CONFIG_FILE %s defines no backends", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Backends {
+		backend := &file.Backends[i]
+		if err := resolveBackend(backend, upstream); err != nil {
+			return fileConfig{}, fmt.Errorf("backend %d (%s): %w", i+1, backend.Name, err)
+		}
+		if names[backend.Name] {
+			return fileConfig{}, fmt.Errorf("backend name %q is used twice", backend.Name)
+		}
+		names[backend.Name] = true
+	}
+
+	groupNames := make(map[string]bool)
+	for i := range file.Groups {
+		group := &file.Groups[i]
+		if err := resolveGroup(group, names); err != nil {
+			return fileConfig{}, fmt.Errorf("group %d (%s): %w", i+1, group.Name, err)
+		}
+		if groupNames[group.Name] {
+			return fileConfig{}, fmt.Errorf("group name %q is used twice", group.Name)
+		}
+		groupNames[group.Name] = true
+	}
+
+	aliasNames := make(map[string]bool)
+	for i := range file.Aliases {
+		alias := &file.Aliases[i]
+		if alias.Name == "" || alias.Model == "" {
+			return fileConfig{}, fmt.Errorf("alias %d (%s): name and model must be set", i+1, alias.Name)
+		}
+		alias.Name = TaggedName(alias.Name)
+		if aliasNames[alias.Name] || groupNames[alias.Name] {
+			return fileConfig{}, fmt.Errorf("alias name %q is used twice", alias.Name)
+		}
+		aliasNames[alias.Name] = true
+		for _, msg := range alias.Messages {
+			if !ValidMessageRole(msg.Role) {
+				return fileConfig{}, fmt.Errorf("alias %s: unsupported message role %q", alias.Name, msg.Role)
+			}
+		}
+	}
+	for _, alias := range file.Aliases {
+		// An alias may add defaults to the model of the same name
+		if target := TaggedName(alias.Model); aliasNames[target] && target != alias.Name {
+			return fileConfig{}, fmt.Errorf("alias %s: model must not be another alias", alias.Name)
+		}
+	}
+
+	for i, metadata := range file.Metadata {
+		if len(metadata.Models) == 0 {
+			return fileConfig{}, fmt.Errorf("metadata %d: models must be set", i+1)
+		}
+		if metadata.ContextLength < 0 || metadata.EmbeddingLength < 0 {
+			return fileConfig{}, fmt.Errorf("metadata %d: lengths must not be negative", i+1)
+		}
+		for _, capability := range metadata.Capabilities {
+			switch capability {
+			case CapabilityCompletion, CapabilityVision, CapabilityTools, CapabilityThinking, CapabilityEmbedding:
+			default:
+				return fileConfig{}, fmt.Errorf("metadata %d: unsupported capability %q", i+1, capability)
+			}
+		}
+	}
+
+	if file.Health == nil { // "health": null
+		file.Health = &defaultHealth
+	}
+	if file.Health.MaxFailures < 0 {
+		return fileConfig{}, fmt.Errorf("health max_failures must not be negative")
+	}
+	return file, nil
+}
+
+// resolveGroup validates a group and fills in member defaults.
+func resolveGroup(group *GroupConfig, backends map[string]bool) error {
+	if group.Name == "" {
+		return fmt.Errorf("name must be set")
+	}
+	switch group.Strategy {
+	case "":
+		group.Strategy = StrategyFailover
+	case StrategyFailover, StrategyRoundRobin, StrategyLeastInflight, StrategyWeighted:
+	default:
+		return fmt.Errorf("unsupported strategy %q: use %s, %s, %s or %s", group.Strategy,
+			StrategyFailover, StrategyRoundRobin, StrategyLeastInflight, StrategyWeighted)
+	}
+	if len(group.Members) == 0 {
+		return fmt.Errorf("members must not be empty")
+	}
+	for i := range group.Members {
+		member := &group.Members[i]
+		if !backends[member.Backend] {
+			return fmt.Errorf("unknown backend %q", member.Backend)
+		}
+		if member.Model == "" {
+			member.Model = group.Name
+		}
+		if member.Weight < 0 {
+			return fmt.Errorf("weight must not be negative")
+		}
+		if member.Weight == 0 {
+			member.Weight = 1
+		}
+	}
+	return nil
+}
+
+// resolveBackend validates a backend, loads its key and applies its client overrides.
+func resolveBackend(backend *BackendConfig, upstream UpstreamConfig) error {
+	if backend.Name == "" || strings.Contains(backend.Name, "/") {
+		return fmt.Errorf("name must be set and must not contain /")
+	}
+	if backend.BaseURL == "" {
+		return fmt.Errorf("base_url must be set")
+	}
+	backend.BaseURL = strings.TrimSuffix(backend.BaseURL, "/")
+	switch backend.Type {
+	case "":
+		backend.Type = BackendTypeOpenAI
+	case BackendTypeOpenAI, BackendTypeAnthropic, BackendTypeGemini, BackendTypeAzure:
+	default:
+		return fmt.Errorf("unsupported type %q: use %s, %s, %s or %s", backend.Type,
+			BackendTypeOpenAI, BackendTypeAnthropic, BackendTypeGemini, BackendTypeAzure)
+	}
+	if backend.Type != BackendTypeAzure && (backend.APIVersion != "" || len(backend.Deployments) > 0) {
+		return fmt.Errorf("api_version and deployments are only supported by type %s", BackendTypeAzure)
+	}
+	if backend.Type == BackendTypeAzure {
+		if backend.APIVersion == "" {
+			backend.APIVersion = defaultAzureAPIVersion
+		}
+		for model, deployment := range backend.Deployments {
+			if model == "" || deployment == "" {
+				return fmt.Errorf("deployments must map model names to deployment names")
+			}
+		}
+		if len(backend.Models) == 0 && len(backend.Deployments) > 0 {
+			for model := range backend.Deployments {
+				backend.Models = append(backend.Models, model)
+			}
+			sort.Strings(backend.Models)
+		}
+	}
+	if backend.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must not be negative")
+	}
+	if backend.MaxTokens == 0 {
+		backend.MaxTokens = defaultMaxTokens
+	}
+
+	sources := 0
+	for _, source := range []string{backend.APIKey, backend.APIKeyEnv, backend.APIKeyFile} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("only one of api_key, api_key_env and api_key_file can be set")
+	}
+	switch {
+	case backend.APIKeyEnv != "":
+		backend.APIKey = strings.TrimSpace(os.Getenv(backend.APIKeyEnv))
+		if backend.APIKey == "" {
+			return fmt.Errorf("api_key_env %s is not set", backend.APIKeyEnv)
+		}
+	case backend.APIKeyFile != "":
+		content, err := os.ReadFile(backend.APIKeyFile)
+		if err != nil {
+			return fmt.Errorf("reading api_key_file: %w", err)
+		}
+		backend.APIKey = strings.TrimSpace(string(content))
+		if backend.APIKey == "" {
+			return fmt.Errorf("api_key_file %s is empty", backend.APIKeyFile)
+		}
+	}
+
+	backend.Upstream = upstream
+	if backend.ConnectTimeout != nil {
+		backend.Upstream.ConnectTimeout = time.Duration(*backend.ConnectTimeout)
+	}
+	if backend.ResponseHeaderTimeout != nil {
+		backend.Upstream.ResponseHeaderTimeout = time.Duration(*backend.ResponseHeaderTimeout)
+	}
+	if backend.Timeout != nil {
+		backend.Upstream.Timeout = time.Duration(*backend.Timeout)
+	}
+	if backend.StreamTimeout != nil {
+		backend.Upstream.StreamTimeout = time.Duration(*backend.StreamTimeout)
+	}
+	if backend.MaxRetries != nil {
+		if *backend.MaxRetries < 0 {
+			return fmt.Errorf("max_retries must not be negative")
+		}
+		backend.Upstream.MaxRetries = *backend.MaxRetries
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeConfigFile points PROXY_CONFIG_FILE at a file holding content.
+func writeConfigFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "proxy.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PROXY_CONFIG_FILE", path)
+}
+
+func TestLoadConfig_DefaultBackend(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("OPENAI_API_BASE_URL", "http://localhost:8000")
+	t.Setenv("OPENAI_ALLOWED_MODELS", "gpt-4o, gpt-4o-mini")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cfg.Backends) != 1 {
+		t.Fatalf("Expected a single backend, got %+v", cfg.Backends)
+	}
+	backend := cfg.Backends[0]
+	if backend.Name != "openai" || backend.BaseURL != "http://localhost:8000" || !reflect.DeepEqual(backend.Models, []string{"gpt-4o", "gpt-4o-mini"}) {
+		t.Errorf("Unexpected default backend: %+v", backend)
+	}
+	if backend.Upstream != cfg.Upstream {
+		t.Errorf("Expected the default backend to use the UPSTREAM_* settings")
+	}
+	if cfg.Metrics {
+		t.Error("Expected metrics to be off by default")
+	}
+	if cfg.PullMode != PullModeVerify {
+		t.Errorf("Expected pulls to only verify models by default, got %q", cfg.PullMode)
+	}
+	if cfg.KeepAlive != 5*time.Minute {
+		t.Errorf("Expected the default keep-alive, got %v", cfg.KeepAlive)
+	}
+	if cfg.ModelsFile != "models.json" {
+		t.Errorf("Expected the default models file, got %q", cfg.ModelsFile)
+	}
+}
+
+func TestLoadConfig_BackendsFile(t *testing.T) {
+	clearAuthEnv(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "openrouter-key")
+	os.WriteFile(keyFile, []byte("sk-or\n"), 0o600)
+	t.Setenv("VLLM_KEY", "sk-vllm")
+	t.Setenv("OPENAI_API_KEY", "sk-upstream")
+	t.Setenv("PROXY_ACCESS_TOKENS", "alice")
+	t.Setenv("UPSTREAM_TIMEOUT", "1m")
+	writeConfigFile(t, `{
+		"prefix_models": true,
+		"backends": [
+			{"name": "vllm", "base_url": "http://vllm:8000/", "api_key_env": "VLLM_KEY", "models": ["llama-*"], "timeout": "10m", "max_retries": 0},
+			{"name": "openrouter", "base_url": "https://openrouter.ai/api", "api_key_file": "`+keyFile+`"},
+			{"name": "openai", "base_url": "https://api.openai.com"},
+			{"name": "claude", "base_url": "https://api.anthropic.com", "type": "anthropic", "api_key": "sk-ant", "max_tokens": 8192},
+			{"name": "azure", "base_url": "https://example.openai.azure.com", "type": "azure", "deployments": {"gpt-4o": "prod-gpt4o", "gpt-4.1": "gpt41"}}
+		]
+	}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cfg.PrefixModels || len(cfg.Backends) != 5 {
+		t.Fatalf("Unexpected config: %+v", cfg)
+	}
+	vllm, openrouter, openai := cfg.Backends[0], cfg.Backends[1], cfg.Backends[2]
+	if vllm.BaseURL != "http://vllm:8000" || vllm.APIKey != "sk-vllm" || vllm.Upstream.Timeout != 10*time.Minute || vllm.Upstream.MaxRetries != 0 {
+		t.Errorf("Unexpected vllm backend: %+v", vllm)
+	}
+	if openrouter.APIKey != "sk-or" || openrouter.Upstream.Timeout != time.Minute || openrouter.Upstream.MaxRetries != 2 {
+		t.Errorf("Unexpected openrouter backend: %+v", openrouter)
+	}
+	if openai.APIKey != "" || openai.Type != BackendTypeOpenAI || openai.MaxTokens != 4096 {
+		t.Errorf("Unexpected openai backend: %+v", openai)
+	}
+	if claude := cfg.Backends[3]; claude.Type != BackendTypeAnthropic || claude.MaxTokens != 8192 {
+		t.Errorf("Unexpected claude backend: %+v", claude)
+	}
+	if azure := cfg.Backends[4]; azure.APIVersion != "2024-10-21" || !reflect.DeepEqual(azure.Models, []string{"gpt-4.1", "gpt-4o"}) {
+		t.Errorf("Expected the default api_version and the deployments' models, got %+v", azure)
+	}
+}
+
+func TestLoadConfig_Groups(t *testing.T) {
+	clearAuthEnv(t)
+	writeConfigFile(t, `{
+		"backends": [
+			{"name": "openai", "base_url": "https://api.openai.com"},
+			{"name": "azure", "base_url": "https://example.openai.azure.com"}
+		],
+		"groups": [
+			{"name": "gpt-4o", "strategy": "weighted", "members": [
+				{"backend": "openai", "weight": 3},
+				{"backend": "azure", "model": "gpt-4o-deployment"}
+			]}
+		],
+		"health": {"max_failures": 5, "cooldown": "1m"}
+	}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []GroupConfig{{Name: "gpt-4o", Strategy: StrategyWeighted, Members: []MemberConfig{
+		{Backend: "openai", Model: "gpt-4o", Weight: 3},
+		{Backend: "azure", Model: "gpt-4o-deployment", Weight: 1},
+	}}}
+	if !reflect.DeepEqual(cfg.Groups, want) {
+		t.Errorf("Got groups %+v, want %+v", cfg.Groups, want)
+	}
+	if cfg.Health.MaxFailures != 5 || cfg.Health.Cooldown != Duration(time.Minute) {
+		t.Errorf("Unexpected health settings: %+v", cfg.Health)
+	}
+
+	writeConfigFile(t, `{"backends": [{"name": "openai", "base_url": "https://api.openai.com"}], "health": {"cooldown": "5s"}}`)
+	if cfg, err = LoadConfig(); err != nil || cfg.Health.MaxFailures != 3 || cfg.Health.Cooldown != Duration(5*time.Second) {
+		t.Errorf("Expected missing health settings to keep their default, got %+v (err: %v)", cfg.Health, err)
+	}
+
+	// Health tracking is on by default
+	writeConfigFile(t, `{"backends": [{"name": "openai", "base_url": "https://api.openai.com"}]}`)
+	if cfg, err = LoadConfig(); err != nil || cfg.Health != defaultHealth {
+		t.Errorf("Expected the default health settings, got %+v (err: %v)", cfg.Health, err)
+	}
+}
+
+func TestLoadConfig_Aliases(t *testing.T) {
+	clearAuthEnv(t)
+	writeConfigFile(t, `{
+		"backends": [{"name": "openai", "base_url": "https://api.openai.com"}],
+		"aliases": [
+			{"name": "reviewer", "model": "gpt-4o", "system": "Review code.", "options": {"temperature": 0.1}, "format": "json"},
+			{"name": "gpt-4o-mini", "model": "gpt-4o-mini"},
+			{"name": "team/helper:v2", "model": "gpt-4o"}
+		]
+	}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, alias := range cfg.Aliases {
+		names = append(names, alias.Name)
+	}
+	if want := []string{"reviewer:latest", "gpt-4o-mini:latest", "team/helper:v2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Got alias names %v, want %v", names, want)
+	}
+	reviewer := cfg.Aliases[0]
+	if reviewer.Model != "gpt-4o" || reviewer.System != "Review code." || reviewer.Options["temperature"] != 0.1 || string(reviewer.Format) != `"json"` {
+		t.Errorf("Unexpected alias: %+v", reviewer)
+	}
+}
+
+func TestLoadConfig_Metadata(t *testing.T) {
+	clearAuthEnv(t)
+	writeConfigFile(t, `{
+		"backends": [{"name": "openai", "base_url": "https://api.openai.com"}],
+		"metadata": [{"models": ["gpt-4o*"], "context_length": 128000, "capabilities": ["completion", "vision"], "family": "gpt"}]
+	}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []MetadataConfig{{Models: []string{"gpt-4o*"}, ContextLength: 128000, Capabilities: []string{CapabilityCompletion, CapabilityVision}, Family: "gpt"}}
+	if !reflect.DeepEqual(cfg.Metadata, want) {
+		t.Errorf("Got metadata %+v, want %+v", cfg.Metadata, want)
+	}
+}
+
+func TestLoadConfig_BackendKeysReplaceGlobalKey(t *testing.T) {
+	clearAuthEnv(t)
+	t.Setenv("PROXY_AUTH_MODE", AuthModeNone)
+	writeConfigFile(t, `{"backends": [{"name": "local", "base_url": "http://localhost:8000", "api_key": "sk-local"}]}`)
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("Expected backend keys to be enough for none mode, got %v", err)
+	}
+
+	writeConfigFile(t, `{"backends": [{"name": "local", "base_url": "http://localhost:8000"}]}`)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected an error for a backend without a key in none mode")
+	}
+}
+
+func TestLoadConfig_BackendKeysRequireClientAuth(t *testing.T) {
+	clearAuthEnv(t)
+	writeConfigFile(t, `{"backends": [{"name": "local", "base_url": "http://localhost:8000", "api_key": "sk-local"}]}`)
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected tokens mode, and an error without access tokens")
+	}
+
+	t.Setenv("PROXY_ACCESS_TOKENS", "alice")
+	cfg, err := LoadConfig()
+	if err != nil || cfg.AuthMode != AuthModeTokens {
+		t.Errorf("Expected tokens mode for backend keys, got %q (err: %v)", cfg.AuthMode, err)
+	}
+
+	// Mixed setups pass the client's key to the backends without one
+	t.Setenv("PROXY_AUTH_MODE", AuthModePassthrough)
+	writeConfigFile(t, `{"backends": [
+		{"name": "local", "base_url": "http://localhost:8000", "api_key": "sk-local", "models": ["llama-*"]},
+		{"name": "openai", "base_url": "https://api.openai.com"}
+	]}`)
+	if cfg, err := LoadConfig(); err != nil || cfg.AuthMode != AuthModePassthrough {
+		t.Errorf("Expected passthrough mode with backend keys to be allowed, got %q (err: %v)", cfg.AuthMode, err)
+	}
+}
+
+func TestLoadConfig_BackendsFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid JSON", `{"backends": [`},
+		{"unknown option", `{"backends": [{"name": "a", "base_url": "http://a", "timeuot": "1s"}]}`},
+		{"no backends", `{"backends": []}`},
+		{"missing base_url", `{"backends": [{"name": "a"}]}`},
+		{"slash in name", `{"backends": [{"name": "a/b", "base_url": "http://a"}]}`},
+		{"duplicate name", `{"backends": [{"name": "a", "base_url": "http://a"}, {"name": "a", "base_url": "http://b"}]}`},
+		{"unset key variable", `{"backends": [{"name": "a", "base_url": "http://a", "api_key_env": "PROXY_TEST_UNSET_KEY"}]}`},
+		{"two key sources", `{"backends": [{"name": "a", "base_url": "http://a", "api_key": "x", "api_key_env": "HOME"}]}`},
+		{"invalid duration", `{"backends": [{"name": "a", "base_url": "http://a", "timeout": "soon"}]}`},
+		{"unsupported type", `{"backends": [{"name": "a", "base_url": "http://a", "type": "cohere"}]}`},
+		{"deployments without azure", `{"backends": [{"name": "a", "base_url": "http://a", "deployments": {"gpt-4o": "d"}}]}`},
+		{"empty deployment", `{"backends": [{"name": "a", "base_url": "http://a", "type": "azure", "deployments": {"gpt-4o": ""}}]}`},
+		{"negative max_tokens", `{"backends": [{"name": "a", "base_url": "http://a", "max_tokens": -1}]}`},
+		{"negative retries", `{"backends": [{"name": "a", "base_url": "http://a", "max_retries": -1}]}`},
+		{"group with unknown backend", `{"backends": [{"name": "a", "base_url": "http://a"}], "groups": [{"name": "g", "members": [{"backend": "b"}]}]}`},
+		{"group without members", `{"backends": [{"name": "a", "base_url": "http://a"}], "groups": [{"name": "g", "members": []}]}`},
+		{"unsupported strategy", `{"backends": [{"name": "a", "base_url": "http://a"}], "groups": [{"name": "g", "strategy": "random", "members": [{"backend": "a"}]}]}`},
+		{"duplicate group", `{"backends": [{"name": "a", "base_url": "http://a"}], "groups": [{"name": "g", "members": [{"backend": "a"}]}, {"name": "g", "members": [{"backend": "a"}]}]}`},
+		{"alias without model", `{"backends": [{"name": "a", "base_url": "http://a"}], "aliases": [{"name": "x"}]}`},
+		{"duplicate alias", `{"backends": [{"name": "a", "base_url": "http://a"}], "aliases": [{"name": "x", "model": "m"}, {"name": "x:latest", "model": "n"}]}`},
+		{"alias shadowing group", `{"backends": [{"name": "a", "base_url": "http://a"}], "groups": [{"name": "g:latest", "members": [{"backend": "a"}]}], "aliases": [{"name": "g", "model": "m"}]}`},
+		{"alias of alias", `{"backends": [{"name": "a", "base_url": "http://a"}], "aliases": [{"name": "x", "model": "m"}, {"name": "y", "model": "x"}]}`},
+		{"metadata without models", `{"backends": [{"name": "a", "base_url": "http://a"}], "metadata": [{"context_length": 8192}]}`},
+		{"unsupported capability", `{"backends": [{"name": "a", "base_url": "http://a"}], "metadata": [{"models": ["m"], "capabilities": ["flying"]}]}`},
+		{"negative context length", `{"backends": [{"name": "a", "base_url": "http://a"}], "metadata": [{"models": ["m"], "context_length": -1}]}`},
+		{"negative max_failures", `{"backends": [{"name": "a", "base_url": "http://a"}], "health": {"max_failures": -1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAuthEnv(t)
+			writeConfigFile(t, tt.content)
+			if _, err := LoadConfig(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
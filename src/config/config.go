@@ -1,8 +1,31 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Authentication modes, see AppConfig.AuthMode.
+const (
+	// AuthModePassthrough forwards the client's Authorization header upstream.
+	AuthModePassthrough = "passthrough"
+	// AuthModeTokens requires one of ProxyAccessTokens and sends OpenAIAPIKey upstream.
+	AuthModeTokens = "tokens"
+	// AuthModeNone accepts every client and sends OpenAIAPIKey upstream.
+	AuthModeNone = "none"
+)
+
+// Pull modes, see AppConfig.PullMode.
+const (
+	// PullModeVerify only lets /api/pull check models that are already served.
+	PullModeVerify = "verify"
+	// PullModeEnable lets /api/pull enable upstream models no backend is
+	// configured to serve.
+	PullModeEnable = "enable"
 )
 
 // AppConfig holds the application configuration.
@@ -11,10 +34,88 @@ type AppConfig struct {
 	Port                string
 	OpenAIBaseURL       string
 	OpenAIAllowedModels []string
+	// MaxTokensField is the chat completion field num_predict is mapped to:
+	// "max_tokens" or "max_completion_tokens" (required by OpenAI reasoning models).
+	MaxTokensField string
+	// TextOnlyModels lists models (glob patterns allowed) that can't handle images.
+	TextOnlyModels []string
+	// TextOnlyImagePolicy is "reject" or "strip" and decides what happens to
+	// images sent to a text-only model.
+	TextOnlyImagePolicy string
+	// ValidateStructuredOutput checks responses against the requested `format`.
+	ValidateStructuredOutput bool
+	// Metrics serves the proxy's counters on /debug/vars.
+	Metrics bool
+	// ReasoningModels lists models (glob patterns allowed) that accept
+	// reasoning_effort, which `think` is mapped to.
+	ReasoningModels []string
+	// ThinkTagStrategy decides what happens to inline <think> blocks: "split"
+	// moves them into `thinking` when thinking is enabled, "strip" also removes
+	// them when it isn't, "none" leaves the content untouched.
+	ThinkTagStrategy string
+	// AuthMode decides how clients authenticate and which key is sent upstream.
+	AuthMode string
+	// OpenAIAPIKey is the upstream key the proxy injects itself. Unused in passthrough mode.
+	OpenAIAPIKey string
+	// ProxyAccessTokens are the bearer tokens clients must present in tokens mode.
+	ProxyAccessTokens []string
+	// Upstream configures the HTTP client used for all upstream requests.
+	Upstream UpstreamConfig
+	// Backends are the upstream APIs requests are routed to by model. Without
+	// PROXY_CONFIG_FILE this is a single backend built from OpenAIBaseURL and
+	// OpenAIAllowedModels.
+	Backends []BackendConfig
+	// PrefixModels lists models in /api/tags as backend/model.
+	PrefixModels bool
+	// Groups are logical models balanced across several backends.
+	Groups []GroupConfig
+	// Aliases are virtual models with default prompts and options.
+	Aliases []AliasConfig
+	// Metadata describes models for /api/show; the first matching entry is used.
+	Metadata []MetadataConfig
+	// PullMode decides whether /api/pull may enable models at runtime.
+	PullMode string
+	// KeepAlive is how long /api/ps lists a model after its last request,
+	// unless the request sets keep_alive.
+	KeepAlive time.Duration
+	// ModelsFile keeps the aliases created through /api/create. Empty keeps
+	// them in memory only.
+	ModelsFile string
+	// Health decides when a failing backend is taken out of rotation.
+	Health HealthConfig
+}
+
+// UpstreamConfig holds the settings of the shared upstream HTTP client.
+// Zero durations disable the corresponding timeout.
+type UpstreamConfig struct {
+	ConnectTimeout        time.Duration // Dialing and TLS handshake
+	ResponseHeaderTimeout time.Duration // Non-streaming responses only send headers once complete
+	IdleConnTimeout       time.Duration
+	Timeout               time.Duration // Total time of a non-streaming request
+	StreamTimeout         time.Duration // Total time of a streaming request
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	// ProxyURL overrides HTTPS_PROXY, HTTP_PROXY and NO_PROXY when set.
+	ProxyURL string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// ClientCertFile and ClientKeyFile hold a PEM client certificate for mTLS.
+	ClientCertFile     string
+	ClientKeyFile      string
+	InsecureSkipVerify bool // Only for development
+	// MaxRetries is how often a request failing with a connection error, 429
+	// or 5xx is retried. Delays grow exponentially from RetryBaseDelay up to
+	// RetryMaxDelay, and a request stops retrying once it would wait longer
+	// than RetryBudget in total.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	RetryBudget    time.Duration
 }
 
 // LoadConfig loads configuration from environment variables.
-func LoadConfig() AppConfig {
+// It fails when the authentication settings are incomplete or contradictory.
+func LoadConfig() (AppConfig, error) {
 	version := os.Getenv("OLLAMA_VERSION")
 	if version == "" {
 		version = "0.5.0" // Default version
@@ -30,21 +131,82 @@ func LoadConfig() AppConfig {
 		openAIBaseURL = "https://api.openai.com" // Default OpenAI URL
 	}
 
-	allowedModelsEnv := os.Getenv("OPENAI_ALLOWED_MODELS")
-	var allowedModelsList []string
-	if allowedModelsEnv != "" {
-		trimmedModels := strings.TrimSpace(allowedModelsEnv)
-		if trimmedModels != "" { // Ensure not to split an empty or whitespace-only string
-			allowedModelsList = strings.Split(trimmedModels, ",")
-			// Trim whitespace from each model ID
-			for i, model := range allowedModelsList {
-				allowedModelsList[i] = strings.TrimSpace(model)
-			}
-		} else {
-			allowedModelsList = []string{} // Ensure it's an empty list if env var is whitespace
+	allowedModelsList := getEnvList("OPENAI_ALLOWED_MODELS")
+
+	// "max_tokens" is understood by most OpenAI-compatible APIs
+	maxTokensField := getEnvChoice("OPENAI_MAX_TOKENS_FIELD", "max_tokens", "max_completion_tokens")
+
+	textOnlyModels := getEnvList("TEXT_ONLY_MODELS")
+	textOnlyImagePolicy := getEnvChoice("TEXT_ONLY_IMAGE_POLICY", "reject", "strip")
+
+	validateStructuredOutput := getEnvBool("VALIDATE_STRUCTURED_OUTPUT")
+	metrics := getEnvBool("PROXY_METRICS")
+
+	reasoningModels := getEnvList("REASONING_MODELS")
+	thinkTagStrategy := getEnvChoice("THINK_TAG_STRATEGY", "split", "strip", "none")
+
+	openAIAPIKey, err := getEnvSecret("OPENAI_API_KEY")
+	if err != nil {
+		return AppConfig{}, err
+	}
+	accessTokens, err := getEnvTokens("PROXY_ACCESS_TOKENS")
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	modelsFile := os.Getenv("PROXY_MODELS_FILE")
+	if modelsFile == "" {
+		modelsFile = "models.json" // Default models file, in the working directory
+	}
+
+	pullMode := getEnvChoice("PROXY_PULL_MODE", PullModeVerify, PullModeEnable)
+
+	keepAlive, err := getEnvDuration("OLLAMA_KEEP_ALIVE", 5*time.Minute)
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	upstream, err := loadUpstreamConfig()
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	file, err := loadBackends(openAIBaseURL, allowedModelsList, upstream)
+	if err != nil {
+		return AppConfig{}, err
+	}
+
+	// Without an upstream key the proxy can only pass the client's key on,
+	// which was the only mode before server-side keys existed.
+	defaultAuthMode := AuthModePassthrough
+	if openAIAPIKey != "" || anyHasKey(file.Backends) {
+		defaultAuthMode = AuthModeTokens
+	}
+	authMode := os.Getenv("PROXY_AUTH_MODE")
+	if authMode == "" {
+		authMode = defaultAuthMode
+	}
+	switch authMode {
+	case AuthModePassthrough:
+		if openAIAPIKey != "" {
+			log.Printf("PROXY_AUTH_MODE is %s, OPENAI_API_KEY is ignored", AuthModePassthrough)
+		}
+		// Clients aren't checked, so anyone could use the backends' own keys
+		if anyHasKey(file.Backends) {
+			log.Printf("PROXY_AUTH_MODE is %s: every client can use the keys of backends that have their own", AuthModePassthrough)
 		}
-	} else {
-		allowedModelsList = []string{} // Ensure it's an empty list if env var is not set
+	case AuthModeTokens, AuthModeNone:
+		if openAIAPIKey == "" && !allHaveKeys(file.Backends) {
+			return AppConfig{}, fmt.Errorf("PROXY_AUTH_MODE %s requires OPENAI_API_KEY or OPENAI_API_KEY_FILE", authMode)
+		}
+		if authMode == AuthModeTokens && len(accessTokens) == 0 {
+			return AppConfig{}, fmt.Errorf("PROXY_AUTH_MODE %s requires PROXY_ACCESS_TOKENS or PROXY_ACCESS_TOKENS_FILE", authMode)
+		}
+		if authMode == AuthModeNone {
+			log.Printf("PROXY_AUTH_MODE is %s: every client can use the upstream API key", AuthModeNone)
+		}
+	default:
+		return AppConfig{}, fmt.Errorf("unsupported PROXY_AUTH_MODE %q: use %s, %s or %s", authMode, AuthModePassthrough, AuthModeTokens, AuthModeNone)
 	}
 
 	return AppConfig{
@@ -52,5 +214,218 @@ func LoadConfig() AppConfig {
 		Port:                port,
 		OpenAIBaseURL:       openAIBaseURL,
 		OpenAIAllowedModels: allowedModelsList, // This will be an empty slice if not set or empty
+		MaxTokensField:      maxTokensField,
+		TextOnlyModels:      textOnlyModels,
+		TextOnlyImagePolicy: textOnlyImagePolicy,
+
+		ValidateStructuredOutput: validateStructuredOutput,
+		Metrics:                  metrics,
+		ReasoningModels:          reasoningModels,
+		ThinkTagStrategy:         thinkTagStrategy,
+		AuthMode:                 authMode,
+		OpenAIAPIKey:             openAIAPIKey,
+		ProxyAccessTokens:        accessTokens,
+		Upstream:                 upstream,
+		Backends:                 file.Backends,
+		PrefixModels:             file.PrefixModels,
+		Groups:                   file.Groups,
+		Aliases:                  file.Aliases,
+		Metadata:                 file.Metadata,
+		PullMode:                 pullMode,
+		KeepAlive:                keepAlive,
+		ModelsFile:               modelsFile,
+		Health:                   *file.Health,
+	}, nil
+}
+
+// allHaveKeys reports whether every backend has its own API key, in which case
+// no global upstream key is needed.
+func allHaveKeys(backends []BackendConfig) bool {
+	for _, backend := range backends {
+		if backend.APIKey == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// anyHasKey reports whether some backend has its own API key.
+func anyHasKey(backends []BackendConfig) bool {
+	for _, backend := range backends {
+		if backend.APIKey != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// loadUpstreamConfig reads the UPSTREAM_* variables.
+func loadUpstreamConfig() (UpstreamConfig, error) {
+	cfg := UpstreamConfig{
+		ProxyURL:           os.Getenv("UPSTREAM_PROXY"),
+		CAFile:             os.Getenv("UPSTREAM_CA_FILE"),
+		ClientCertFile:     os.Getenv("UPSTREAM_CLIENT_CERT_FILE"),
+		ClientKeyFile:      os.Getenv("UPSTREAM_CLIENT_KEY_FILE"),
+		InsecureSkipVerify: getEnvBool("UPSTREAM_INSECURE_SKIP_VERIFY"),
+	}
+	durations := []struct {
+		key    string
+		value  *time.Duration
+		preset time.Duration
+	}{
+		{"UPSTREAM_CONNECT_TIMEOUT", &cfg.ConnectTimeout, 10 * time.Second},
+		{"UPSTREAM_RESPONSE_HEADER_TIMEOUT", &cfg.ResponseHeaderTimeout, 0},
+		{"UPSTREAM_IDLE_CONN_TIMEOUT", &cfg.IdleConnTimeout, 90 * time.Second},
+		{"UPSTREAM_TIMEOUT", &cfg.Timeout, 5 * time.Minute},
+		{"UPSTREAM_STREAM_TIMEOUT", &cfg.StreamTimeout, 0}, // Streams end when the client disconnects
+		{"UPSTREAM_RETRY_BASE_DELAY", &cfg.RetryBaseDelay, 500 * time.Millisecond},
+		{"UPSTREAM_RETRY_MAX_DELAY", &cfg.RetryMaxDelay, 10 * time.Second},
+		{"UPSTREAM_RETRY_BUDGET", &cfg.RetryBudget, 30 * time.Second},
+	}
+	for _, d := range durations {
+		value, err := getEnvDuration(d.key, d.preset)
+		if err != nil {
+			return cfg, err
+		}
+		*d.value = value
+	}
+	var err error
+	if cfg.MaxIdleConns, err = getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 100); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxIdleConnsPerHost, err = getEnvInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 10); err != nil {
+		return cfg, err
+	}
+	if cfg.MaxRetries, err = getEnvInt("UPSTREAM_MAX_RETRIES", 2); err != nil {
+		return cfg, err
+	}
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return cfg, fmt.Errorf("UPSTREAM_CLIENT_CERT_FILE and UPSTREAM_CLIENT_KEY_FILE must be set together")
+	}
+	if cfg.InsecureSkipVerify {
+		log.Printf("UPSTREAM_INSECURE_SKIP_VERIFY is set: upstream TLS certificates are not verified")
+	}
+	return cfg, nil
+}
+
+// getEnvDuration reads a duration such as "30s" or "5m". "0" disables a timeout.
+func getEnvDuration(key string, preset time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return preset, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration such as 30s", key, value)
+	}
+	return duration, nil
+}
+
+// getEnvInt reads a non-negative integer.
+func getEnvInt(key string, preset int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return preset, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a non-negative integer", key, value)
+	}
+	return number, nil
+}
+
+// getEnvSecret reads a secret either from the variable key or from the file
+// named by key+"_FILE", e.g. a Docker secret. Setting both is an error.
+func getEnvSecret(key string) (string, error) {
+	value := strings.TrimSpace(os.Getenv(key))
+	file := os.Getenv(key + "_FILE")
+	if file == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("only one of %s and %s_FILE can be set", key, key)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading %s_FILE: %w", key, err)
+	}
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", fmt.Errorf("%s_FILE %s is empty", key, file)
+	}
+	return secret, nil
+}
+
+// getEnvTokens reads a list of secrets, either comma-separated from the
+// variable key or one per line from the file named by key+"_FILE". Blank lines
+// and lines starting with # are skipped in the file.
+func getEnvTokens(key string) ([]string, error) {
+	file := os.Getenv(key + "_FILE")
+	if file == "" {
+		var tokens []string
+		for _, token := range getEnvList(key) {
+			if token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		return tokens, nil
+	}
+	if strings.TrimSpace(os.Getenv(key)) != "" {
+		return nil, fmt.Errorf("only one of %s and %s_FILE can be set", key, key)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s_FILE: %w", key, err)
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens = append(tokens, line)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s_FILE %s contains no tokens", key, file)
+	}
+	return tokens, nil
+}
+
+// getEnvBool reads a boolean environment variable. Anything strconv.ParseBool
+// doesn't accept as true, including an unset variable, is false.
+func getEnvBool(key string) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	return err == nil && value
+}
+
+// getEnvList reads a comma-separated list from an environment variable.
+// It returns an empty slice if the variable is not set or only whitespace.
+func getEnvList(key string) []string {
+	trimmed := strings.TrimSpace(os.Getenv(key))
+	if trimmed == "" { // Ensure not to split an empty or whitespace-only string
+		return []string{}
+	}
+	list := strings.Split(trimmed, ",")
+	// Trim whitespace from each item
+	for i, item := range list {
+		list[i] = strings.TrimSpace(item)
+	}
+	return list
+}
+
+// getEnvChoice reads an environment variable that must be one of the given
+// values. The first value is the default, used when the variable is not set
+// or holds an unsupported value.
+func getEnvChoice(key string, values ...string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return values[0]
+	}
+	for _, allowed := range values {
+		if value == allowed {
+			return value
+		}
 	}
+	log.Printf("Unsupported %s %q, using %s", key, value, values[0])
+	return values[0]
 }
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// clearAuthEnv unsets the authentication variables for the duration of a test.
+func clearAuthEnv(t *testing.T) {
+	for _, key := range []string{"PROXY_AUTH_MODE", "OPENAI_API_KEY", "OPENAI_API_KEY_FILE", "PROXY_ACCESS_TOKENS", "PROXY_ACCESS_TOKENS_FILE", "PROXY_CONFIG_FILE"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestLoadConfig_AuthDefaults(t *testing.T) {
+	clearAuthEnv(t)
+	cfg, err := LoadConfig()
+	if err != nil || cfg.AuthMode != AuthModePassthrough {
+		t.Errorf("Expected passthrough without an upstream key, got %q (err: %v)", cfg.AuthMode, err)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-upstream")
+	t.Setenv("PROXY_ACCESS_TOKENS", "alice, bob,")
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.AuthMode != AuthModeTokens || cfg.OpenAIAPIKey != "sk-upstream" {
+		t.Errorf("Expected tokens mode with the upstream key, got %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.ProxyAccessTokens, []string{"alice", "bob"}) {
+		t.Errorf("Unexpected access tokens: %v", cfg.ProxyAccessTokens)
+	}
+}
+
+func TestLoadConfig_AuthFromFiles(t *testing.T) {
+	clearAuthEnv(t)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	tokensFile := filepath.Join(dir, "tokens")
+	os.WriteFile(keyFile, []byte("sk-from-file\n"), 0o600)
+	os.WriteFile(tokensFile, []byte("# laptops\nalice\n\nbob\n"), 0o600)
+
+	t.Setenv("OPENAI_API_KEY_FILE", keyFile)
+	t.Setenv("PROXY_ACCESS_TOKENS_FILE", tokensFile)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.OpenAIAPIKey != "sk-from-file" || !reflect.DeepEqual(cfg.ProxyAccessTokens, []string{"alice", "bob"}) {
+		t.Errorf("Unexpected secrets: %q %v", cfg.OpenAIAPIKey, cfg.ProxyAccessTokens)
+	}
+}
+
+func TestLoadConfig_AuthErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"tokens without upstream key", map[string]string{"PROXY_AUTH_MODE": "tokens", "PROXY_ACCESS_TOKENS": "alice"}},
+		{"tokens without access tokens", map[string]string{"OPENAI_API_KEY": "sk-upstream"}},
+		{"none without upstream key", map[string]string{"PROXY_AUTH_MODE": "none"}},
+		{"unknown mode", map[string]string{"PROXY_AUTH_MODE": "open"}},
+		{"key and key file", map[string]string{"OPENAI_API_KEY": "sk", "OPENAI_API_KEY_FILE": "/nonexistent"}},
+		{"missing key file", map[string]string{"OPENAI_API_KEY_FILE": "/nonexistent"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAuthEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if _, err := LoadConfig(); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
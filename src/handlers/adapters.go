@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// Backends that don't speak the OpenAI API are reached through adapters that
+// convert OpenAI requests into the backend's format and its answers back into
+// OpenAI chat completions and SSE streams. This file holds what the adapters
+// share.
+
+// setAuthHeaders authenticates req the way backend's API expects. authToken
+// is an Authorization header value.
+func setAuthHeaders(req *http.Request, backend *backends.Backend, authToken string) {
+	switch backend.Type {
+	case config.BackendTypeAnthropic:
+		setAnthropicHeaders(req, authToken)
+	case config.BackendTypeGemini:
+		req.Header.Set("x-goog-api-key", bearerToken(authToken))
+	case config.BackendTypeAzure:
+		req.Header.Set("api-key", bearerToken(authToken))
+	default:
+		req.Header.Set("Authorization", authToken)
+	}
+}
+
+// bearerToken strips the Bearer prefix from an Authorization header value.
+func bearerToken(authToken string) string {
+	if token, ok := strings.CutPrefix(authToken, "Bearer "); ok {
+		return token
+	}
+	return authToken
+}
+
+// unsupportedPathResponse answers a request for an endpoint backend's API
+// doesn't have with 501 Not Implemented.
+func unsupportedPathResponse(backend *backends.Backend, path string) *http.Response {
+	message := fmt.Sprintf("backend %s (%s) does not support %s", backend.Name, backend.Type, path)
+	return errorResponse(http.StatusNotImplemented, "not_implemented", message)
+}
+
+// errorResponse builds an answer with an OpenAI style error body.
+func errorResponse(status int, errType, message string) *http.Response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": errType},
+	})
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// replaceBody swaps the body of a successful response for the JSON encoding
+// of v.
+func replaceBody(resp *http.Response, v interface{}) error {
+	converted, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(converted))
+	resp.ContentLength = int64(len(converted))
+	resp.Header.Set("Content-Type", "application/json")
+	return nil
+}
+
+// translatedBody is the converted stream read by handlers. Closing it also
+// closes the upstream body, which ends the translating goroutine.
+type translatedBody struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+func (b *translatedBody) Close() error {
+	b.PipeReader.Close()
+	return b.upstream.Close()
+}
+
+// chunkWriter writes OpenAI chat completion chunks as SSE events.
+type chunkWriter struct {
+	w         *io.PipeWriter
+	id, model string
+}
+
+// write sends a chunk with a single choice, or the final usage chunk without
+// choices when usage is set.
+func (c *chunkWriter) write(delta models.OpenAIChatMessage, finishReason string, usage *models.OpenAIUsage) error {
+	chunk := models.OpenAIStreamChunk{
+		ID:      c.id,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   c.model,
+		Choices: []models.OpenAIChatChoice{{Delta: delta, FinishReason: finishReason}},
+		Usage:   usage,
+	}
+	if usage != nil {
+		chunk.Choices = []models.OpenAIChatChoice{}
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.w, "data: %s\n\n", data)
+	return err
+}
+
+// finish ends the stream with the usage chunk and [DONE].
+func (c *chunkWriter) finish(usage *models.OpenAIUsage) {
+	err := c.write(models.OpenAIChatMessage{}, "", usage)
+	if err == nil {
+		_, err = io.WriteString(c.w, "data: [DONE]\n\n")
+	}
+	c.w.CloseWithError(err) // A nil error is a regular EOF
+}
+
+// messageText returns the text of a message, including text content parts.
+func messageText(msg models.OpenAIChatMessage) string {
+	if len(msg.ContentParts) == 0 {
+		return msg.Content
+	}
+	var texts []string
+	for _, part := range msg.ContentParts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// parseDataURI splits a base64 data URI into its media type and data.
+func parseDataURI(url string) (mediaType, data string, err error) {
+	header, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	mediaType, isBase64 := strings.CutSuffix(header, ";base64")
+	if !strings.HasPrefix(url, "data:") || !found || !isBase64 {
+		return "", "", fmt.Errorf("unsupported image data URI")
+	}
+	return mediaType, data, nil
+}
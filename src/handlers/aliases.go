@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// applyChatAlias fills in the defaults of alias the request leaves open, as
+// Ollama does with the SYSTEM, PARAMETER and MESSAGE of a Modelfile. The
+// alias's messages start the conversation, and the system prompt is only added
+// when the conversation has none.
+func applyChatAlias(req *models.OllamaChatRequest, alias *backends.Alias) {
+	if len(alias.Messages) > 0 {
+		messages := make([]models.OllamaChatMessage, 0, len(alias.Messages)+len(req.Messages))
+		for _, msg := range alias.Messages {
+			messages = append(messages, models.OllamaChatMessage{Role: msg.Role, Content: msg.Content})
+		}
+		req.Messages = append(messages, req.Messages...)
+	}
+	if alias.System != "" && !hasSystemMessage(req.Messages) {
+		system := models.OllamaChatMessage{Role: "system", Content: alias.System}
+		req.Messages = append([]models.OllamaChatMessage{system}, req.Messages...)
+	}
+	req.Options = aliasOptions(alias, req.Options)
+	if isEmptyFormat(req.Format) {
+		req.Format = alias.Format
+	}
+}
+
+// applyGenerateAlias is applyChatAlias for /api/generate, which has no use
+// for the alias's messages.
+func applyGenerateAlias(req *models.OllamaGenerateRequest, alias *backends.Alias) {
+	if req.System == "" {
+		req.System = alias.System
+	}
+	req.Options = aliasOptions(alias, req.Options)
+	if isEmptyFormat(req.Format) {
+		req.Format = alias.Format
+	}
+}
+
+func hasSystemMessage(messages []models.OllamaChatMessage) bool {
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasOptions returns the default options of alias overridden by options.
+func aliasOptions(alias *backends.Alias, options map[string]interface{}) map[string]interface{} {
+	if len(alias.Options) == 0 {
+		return options
+	}
+	merged := make(map[string]interface{}, len(alias.Options)+len(options))
+	for key, value := range alias.Options {
+		merged[key] = value
+	}
+	for key, value := range options {
+		merged[key] = value
+	}
+	return merged
+}
+
+// writeAliasError answers a failed change of the alias name, see
+// aliasErrorStatus.
+func writeAliasError(w http.ResponseWriter, action, name string, err error) {
+	status, message := aliasErrorStatus(action, name, err)
+	writeOllamaError(w, status, message)
+}
+
+// aliasErrorStatus returns the status and message for a failed change of the
+// alias name: unknown models are not found, models of the configuration file
+// can't be changed, and anything else failed to save.
+func aliasErrorStatus(action, name string, err error) (int, string) {
+	var unknownModel *backends.ErrUnknownModel
+	switch {
+	case errors.As(err, &unknownModel):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, backends.ErrConfiguredModel):
+		return http.StatusBadRequest, err.Error()
+	default:
+		log.Printf("Error %s model %s: %v", action, name, err)
+		return http.StatusInternalServerError, "failed to save the model"
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// reviewerAlias is the alias reviewer for gpt-4o.
+func reviewerAlias() config.AliasConfig {
+	return config.AliasConfig{
+		Name:    "reviewer:latest",
+		Model:   "gpt-4o",
+		System:  "You review code.",
+		Options: map[string]interface{}{"temperature": 0.1, "num_predict": 256.0},
+		Format:  json.RawMessage(`"json"`),
+	}
+}
+
+func TestChatHandler_Alias(t *testing.T) {
+	var rawRequest map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawRequest = nil
+		json.NewDecoder(r.Body).Decode(&rawRequest)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Model:   "gpt-4o-2024-08-06",
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "{}"}, FinishReason: "stop"}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias())
+
+	chat := func(body string) (int, models.OllamaChatResponse) {
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		rr := httptest.NewRecorder()
+		ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), registry)
+		var resp models.OllamaChatResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+
+	code, resp := chat(`{"model":"reviewer","stream":false,"messages":[{"role":"user","content":"Hi"}]}`)
+	if code != http.StatusOK || resp.Model != "reviewer" {
+		t.Fatalf("Expected an answer for the alias, got %d %+v", code, resp)
+	}
+	messages, _ := rawRequest["messages"].([]interface{})
+	if rawRequest["model"] != "gpt-4o" || len(messages) != 2 || messages[0].(map[string]interface{})["content"] != "You review code." {
+		t.Errorf("Expected the alias's model and system prompt upstream, got %+v", rawRequest)
+	}
+	if rawRequest["temperature"] != 0.1 || rawRequest["max_tokens"] != float64(256) {
+		t.Errorf("Expected the alias's options upstream, got %+v", rawRequest)
+	}
+	if format, _ := rawRequest["response_format"].(map[string]interface{}); format["type"] != "json_object" {
+		t.Errorf("Expected the alias's format upstream, got %+v", rawRequest["response_format"])
+	}
+
+	// The request's own system prompt, options and format win
+	code, _ = chat(`{"model":"reviewer:latest","stream":false,"format":"","options":{"temperature":0.9},"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Hi"}]}`)
+	messages, _ = rawRequest["messages"].([]interface{})
+	if code != http.StatusOK || len(messages) != 2 || messages[0].(map[string]interface{})["content"] != "Be brief." {
+		t.Errorf("Expected the request's system prompt to be kept, got %d %+v", code, rawRequest)
+	}
+	if rawRequest["temperature"] != 0.9 || rawRequest["max_tokens"] != float64(256) {
+		t.Errorf("Expected request options to override the alias's one by one, got %+v", rawRequest)
+	}
+}
+
+func TestGenerateHandler_AliasSystemPrompt(t *testing.T) {
+	var openAIReq models.OpenAIChatRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "{}"}, FinishReason: "stop"}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"reviewer","prompt":"Hi","stream":false}`
+	req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias()))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if openAIReq.Model != "gpt-4o" || len(openAIReq.Messages) != 2 || openAIReq.Messages[0].Content != "You review code." {
+		t.Errorf("Expected the alias's system prompt upstream, got %+v", openAIReq)
+	}
+}
+
+func TestGetModelsHandler_ListsAliases(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.OpenAIModelsResponse{Object: "list", Data: []models.OpenAIModel{{ID: "gpt-4o", Object: "model", Created: 1700000000}}})
+	}))
+	defer mockOpenAIServer.Close()
+
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias()))
+
+	var resp models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || len(resp.Models) != 2 {
+		t.Fatalf("Expected gpt-4o and the alias, got %d %+v", rr.Code, resp.Models)
+	}
+	alias := resp.Models[1]
+	if alias.Name != "reviewer:latest" || alias.Details.ParentModel != "gpt-4o" {
+		t.Errorf("Unexpected alias entry: %+v", alias)
+	}
+}
@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// anthropicVersion is the Messages API version the adapter is written against.
+const anthropicVersion = "2023-06-01"
+
+// anthropicThinkingBudgets maps reasoning_effort to a thinking token budget.
+// 1024 is the smallest budget Anthropic accepts.
+var anthropicThinkingBudgets = map[string]int{"low": 1024, "medium": 4096, "high": 16384}
+
+// postToAnthropic sends an OpenAI chat completion request to an Anthropic
+// backend's /v1/messages and converts a successful answer back into an OpenAI
+// chat completion or SSE stream, so handlers can treat every backend alike.
+// Error responses are passed on unchanged; like OpenAI's they carry an
+// "error" object. Other endpoints don't exist on Anthropic and are answered
+// with 501 Not Implemented, requests that can't be converted with 400.
+func postToAnthropic(ctx context.Context, backend *backends.Backend, path, authToken string, payload interface{}, stream bool) (*http.Response, error) {
+	chatReq, ok := payload.(models.OpenAIChatRequest)
+	if path != "/v1/chat/completions" || !ok {
+		return unsupportedPathResponse(backend, path), nil
+	}
+	anthropicReq, err := toAnthropicRequest(chatReq, backend.MaxTokens)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid_request_error", err.Error()), nil
+	}
+	reqBodyBytes, err := json.Marshal(anthropicReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling Anthropic request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", backend.BaseURL+"/v1/messages", bytes.NewBuffer(reqBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request to Anthropic: %w", err)
+	}
+	setAuthHeaders(httpReq, backend, authToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := backend.Client.Do(httpReq, stream)
+	if err != nil {
+		return nil, fmt.Errorf("making request to Anthropic: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	if stream {
+		pr, pw := io.Pipe()
+		go translateAnthropicStream(resp.Body, pw)
+		resp.Body = &translatedBody{PipeReader: pr, upstream: resp.Body}
+		return resp, nil
+	}
+
+	var anthropicResp models.AnthropicMessagesResponse
+	err = json.NewDecoder(resp.Body).Decode(&anthropicResp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decoding Anthropic response: %w", err)
+	}
+	if err := replaceBody(resp, fromAnthropicResponse(anthropicResp)); err != nil {
+		return nil, fmt.Errorf("marshalling converted Anthropic response: %w", err)
+	}
+	return resp, nil
+}
+
+// setAnthropicHeaders authenticates req the way Anthropic expects. authToken
+// is an Authorization header value, its Bearer prefix is removed.
+func setAnthropicHeaders(req *http.Request, authToken string) {
+	req.Header.Set("x-api-key", bearerToken(authToken))
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// decodeAnthropicModels reads Anthropic's model list as OpenAI models.
+func decodeAnthropicModels(body io.Reader) ([]models.OpenAIModel, error) {
+	var anthropicResp models.AnthropicModelsResponse
+	if err := json.NewDecoder(body).Decode(&anthropicResp); err != nil {
+		return nil, err
+	}
+	openAIModels := make([]models.OpenAIModel, len(anthropicResp.Data))
+	for i, model := range anthropicResp.Data {
+		openAIModels[i] = models.OpenAIModel{ID: model.ID, Object: "model", OwnedBy: "anthropic"}
+		if created, err := time.Parse(time.RFC3339, model.CreatedAt); err == nil {
+			openAIModels[i].Created = created.Unix()
+		}
+	}
+	return openAIModels, nil
+}
+
+// toAnthropicRequest converts an OpenAI chat completion request. System
+// messages become the system prompt, tool results become user messages and
+// consecutive messages of the same role are merged, as Anthropic requires
+// roles to alternate. maxTokens is used when the request sets no limit.
+func toAnthropicRequest(req models.OpenAIChatRequest, maxTokens int) (models.AnthropicMessagesRequest, error) {
+	out := models.AnthropicMessagesRequest{
+		Model:         req.Model,
+		Stream:        req.Stream,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		TopK:          req.TopK,
+		StopSequences: req.Stop,
+	}
+
+	switch {
+	case req.MaxTokens != nil:
+		out.MaxTokens = *req.MaxTokens
+	case req.MaxCompletionTokens != nil:
+		out.MaxTokens = *req.MaxCompletionTokens
+	case maxTokens > 0:
+		out.MaxTokens = maxTokens
+	default:
+		out.MaxTokens = 4096
+	}
+
+	if budget, ok := anthropicThinkingBudgets[req.ReasoningEffort]; ok {
+		out.Thinking = &models.AnthropicThinking{Type: "enabled", BudgetTokens: budget}
+		out.MaxTokens += budget // The budget is part of max_tokens
+		// Extended thinking doesn't allow changing the sampling
+		out.Temperature, out.TopP, out.TopK = nil, nil, nil
+	}
+	if req.ResponseFormat != nil {
+		log.Printf("Anthropic has no response_format, format is not forwarded (model %s)", req.Model)
+	}
+	if req.Seed != nil || req.PresencePenalty != nil || req.FrequencyPenalty != nil {
+		log.Printf("Anthropic doesn't support seed and penalties, they are ignored (model %s)", req.Model)
+	}
+
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if len(schema) == 0 || string(schema) == "null" {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		out.Tools = append(out.Tools, models.AnthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	var system []string
+	for i, msg := range req.Messages {
+		var blocks []models.AnthropicContentBlock
+		role := "user"
+		switch msg.Role {
+		case "system":
+			if text := messageText(msg); text != "" {
+				system = append(system, text)
+			}
+			continue
+		case "tool":
+			blocks = append(blocks, models.AnthropicContentBlock{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   messageText(msg),
+			})
+		case "assistant":
+			role = "assistant"
+			if msg.Content != "" {
+				blocks = append(blocks, models.AnthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					log.Printf("Invalid arguments of tool call %s, sending {} to Anthropic", call.ID)
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, models.AnthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+		default:
+			var err error
+			if blocks, err = userContentBlocks(msg); err != nil {
+				return out, fmt.Errorf("message %d: %w", i, err)
+			}
+		}
+		if len(blocks) == 0 {
+			continue // Anthropic rejects empty messages
+		}
+
+		if last := len(out.Messages) - 1; last >= 0 && out.Messages[last].Role == role {
+			out.Messages[last].Content = append(out.Messages[last].Content, blocks...)
+		} else {
+			out.Messages = append(out.Messages, models.AnthropicMessage{Role: role, Content: blocks})
+		}
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out, nil
+}
+
+// userContentBlocks converts the text and images of a user message.
+func userContentBlocks(msg models.OpenAIChatMessage) ([]models.AnthropicContentBlock, error) {
+	if len(msg.ContentParts) == 0 {
+		if msg.Content == "" {
+			return nil, nil
+		}
+		return []models.AnthropicContentBlock{{Type: "text", Text: msg.Content}}, nil
+	}
+
+	var blocks []models.AnthropicContentBlock
+	for _, part := range msg.ContentParts {
+		switch {
+		case part.Type == "text" && part.Text != "":
+			blocks = append(blocks, models.AnthropicContentBlock{Type: "text", Text: part.Text})
+		case part.Type == "image_url" && part.ImageURL != nil:
+			source, err := anthropicImageSource(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, models.AnthropicContentBlock{Type: "image", Source: source})
+		}
+	}
+	return blocks, nil
+}
+
+// anthropicImageSource turns a data URI into a base64 image source. Other
+// URLs are passed on for Anthropic to fetch.
+func anthropicImageSource(url string) (*models.AnthropicImageSource, error) {
+	if !strings.HasPrefix(url, "data:") {
+		return &models.AnthropicImageSource{Type: "url", URL: url}, nil
+	}
+	mediaType, data, err := parseDataURI(url)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AnthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}, nil
+}
+
+// fromAnthropicResponse converts a complete Anthropic response into an OpenAI
+// chat completion.
+func fromAnthropicResponse(resp models.AnthropicMessagesResponse) models.OpenAIChatResponse {
+	message := models.OpenAIChatMessage{Role: "assistant"}
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			message.Content += block.Text
+		case "thinking":
+			message.ReasoningContent += block.Thinking
+		case "tool_use":
+			message.ToolCalls = append(message.ToolCalls, models.OpenAIToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: models.OpenAIToolCallFunction{Name: block.Name, Arguments: toolInputString(block.Input)},
+			})
+		}
+	}
+	return models.OpenAIChatResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   resp.Model,
+		Choices: []models.OpenAIChatChoice{{Message: message, FinishReason: anthropicFinishReason(resp.StopReason)}},
+		Usage:   anthropicUsage(resp.Usage.InputTokens, resp.Usage.OutputTokens),
+	}
+}
+
+// toolInputString encodes a tool_use input as OpenAI function arguments.
+func toolInputString(input json.RawMessage) string {
+	if len(input) == 0 {
+		return "{}"
+	}
+	return string(input)
+}
+
+// anthropicFinishReason maps a stop_reason to an OpenAI finish_reason.
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	}
+	return "stop" // end_turn, stop_sequence
+}
+
+func anthropicUsage(inputTokens, outputTokens int) *models.OpenAIUsage {
+	return &models.OpenAIUsage{
+		PromptTokens:     inputTokens,
+		CompletionTokens: outputTokens,
+		TotalTokens:      inputTokens + outputTokens,
+	}
+}
+
+// translateAnthropicStream reads Anthropic's SSE events from body and writes
+// the equivalent OpenAI chat completion chunks to w, ending with a usage chunk
+// and [DONE] once message_stop arrives. An error event, a read error or a
+// stream ending early closes w with an error, which the reader sees.
+func translateAnthropicStream(body io.Reader, w *io.PipeWriter) {
+	var (
+		chunks       = &chunkWriter{w: w}
+		inputTokens  int
+		outputTokens int
+		toolIndexes  = make(map[int]int) // Content block index -> tool call index
+	)
+	write := chunks.write
+	events := newSSEReader(body)
+	for {
+		event, err := events.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // No message_stop
+			}
+			w.CloseWithError(err)
+			return
+		}
+
+		var anthropicEvent models.AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(event.Data), &anthropicEvent); err != nil {
+			log.Printf("Error unmarshalling Anthropic stream event '%s': %v", event.Data, err)
+			continue // Skip malformed event
+		}
+
+		switch anthropicEvent.Type {
+		case "message_start":
+			if msg := anthropicEvent.Message; msg != nil {
+				chunks.id, chunks.model, inputTokens = msg.ID, msg.Model, msg.Usage.InputTokens
+			}
+			err = write(models.OpenAIChatMessage{Role: "assistant"}, "", nil)
+		case "content_block_start":
+			block := anthropicEvent.ContentBlock
+			switch {
+			case block == nil:
+			case block.Type == "tool_use":
+				index := len(toolIndexes)
+				toolIndexes[anthropicEvent.Index] = index
+				err = write(models.OpenAIChatMessage{ToolCalls: []models.OpenAIToolCall{{
+					Index:    &index,
+					ID:       block.ID,
+					Type:     "function",
+					Function: models.OpenAIToolCallFunction{Name: block.Name},
+				}}}, "", nil)
+			case block.Type == "text" && block.Text != "":
+				err = write(models.OpenAIChatMessage{Content: block.Text}, "", nil)
+			}
+		case "content_block_delta":
+			delta := anthropicEvent.Delta
+			switch {
+			case delta == nil:
+			case delta.Type == "text_delta":
+				err = write(models.OpenAIChatMessage{Content: delta.Text}, "", nil)
+			case delta.Type == "thinking_delta":
+				err = write(models.OpenAIChatMessage{ReasoningContent: delta.Thinking}, "", nil)
+			case delta.Type == "input_json_delta":
+				index := toolIndexes[anthropicEvent.Index]
+				err = write(models.OpenAIChatMessage{ToolCalls: []models.OpenAIToolCall{{
+					Index:    &index,
+					Function: models.OpenAIToolCallFunction{Arguments: delta.PartialJSON},
+				}}}, "", nil)
+			}
+		case "message_delta":
+			if anthropicEvent.Usage != nil {
+				outputTokens = anthropicEvent.Usage.OutputTokens
+			}
+			if anthropicEvent.Delta != nil && anthropicEvent.Delta.StopReason != "" {
+				err = write(models.OpenAIChatMessage{}, anthropicFinishReason(anthropicEvent.Delta.StopReason), nil)
+			}
+		case "message_stop":
+			chunks.finish(anthropicUsage(inputTokens, outputTokens))
+			return
+		case "error":
+			message := event.Data
+			if anthropicEvent.Error != nil {
+				message = anthropicEvent.Error.Type + ": " + anthropicEvent.Error.Message
+			}
+			w.CloseWithError(fmt.Errorf("Anthropic stream error: %s", message))
+			return
+		}
+		// ping and signature deltas carry nothing to forward
+
+		if err != nil {
+			w.CloseWithError(err) // The reader is gone
+			return
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// anthropicBackend serves every model from the Anthropic API at url.
+func anthropicBackend(url string) config.BackendConfig {
+	return config.BackendConfig{
+		Name:      "anthropic",
+		BaseURL:   url,
+		Type:      config.BackendTypeAnthropic,
+		MaxTokens: 1000,
+		APIKey:    "sk-ant",
+	}
+}
+
+func TestToAnthropicRequest(t *testing.T) {
+	maxTokens := 200
+	temperature := 0.5
+	topK := 40
+	req := models.OpenAIChatRequest{
+		Model: "claude-sonnet-4",
+		Messages: []models.OpenAIChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "What's in the picture?", ContentParts: []models.OpenAIContentPart{
+				{Type: "text", Text: "What's in the picture?"},
+				{Type: "image_url", ImageURL: &models.OpenAIImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}},
+			}},
+			{Role: "user", Content: "And the weather?"},
+			{Role: "assistant", ToolCalls: []models.OpenAIToolCall{{ID: "call_1", Type: "function", Function: models.OpenAIToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}}}},
+			{Role: "tool", ToolCallID: "call_1", Content: "Sunny"},
+		},
+		Tools: []models.OpenAITool{{Type: "function", Function: models.OpenAIToolFunction{Name: "weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}}},
+		OpenAISamplingParams: models.OpenAISamplingParams{
+			MaxTokens:   &maxTokens,
+			Temperature: &temperature,
+			TopK:        &topK,
+			Stop:        []string{"END"},
+		},
+	}
+
+	got, err := toAnthropicRequest(req, 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := models.AnthropicMessagesRequest{
+		Model:  "claude-sonnet-4",
+		System: "Be brief.",
+		Messages: []models.AnthropicMessage{
+			{Role: "user", Content: []models.AnthropicContentBlock{
+				{Type: "text", Text: "What's in the picture?"},
+				{Type: "image", Source: &models.AnthropicImageSource{Type: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="}},
+				{Type: "text", Text: "And the weather?"}, // Merged, roles must alternate
+			}},
+			{Role: "assistant", Content: []models.AnthropicContentBlock{
+				{Type: "tool_use", ID: "call_1", Name: "weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+			}},
+			{Role: "user", Content: []models.AnthropicContentBlock{
+				{Type: "tool_result", ToolUseID: "call_1", Content: "Sunny"},
+			}},
+		},
+		MaxTokens:     200,
+		Tools:         []models.AnthropicTool{{Name: "weather", InputSchema: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}},
+		Temperature:   &temperature,
+		TopK:          &topK,
+		StopSequences: []string{"END"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("Got  %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	// Without num_predict the backend's limit applies; thinking adds its budget
+	req.MaxTokens = nil
+	req.ReasoningEffort = "low"
+	got, _ = toAnthropicRequest(req, 1000)
+	if got.MaxTokens != 2024 || got.Thinking == nil || got.Thinking.BudgetTokens != 1024 || got.Temperature != nil || got.TopK != nil {
+		t.Errorf("Unexpected limits with thinking: max_tokens %d, thinking %+v, temperature %v, top_k %v", got.MaxTokens, got.Thinking, got.Temperature, got.TopK)
+	}
+}
+
+func TestChatHandler_AnthropicNonStreaming(t *testing.T) {
+	var received models.AnthropicMessagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "sk-ant" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("Unexpected request %s with key %q", r.URL.Path, r.Header.Get("x-api-key"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4",
+			"content":[{"type":"thinking","thinking":"Paris, obviously.","signature":"x"},{"type":"text","text":"Let me check."},
+				{"type":"tool_use","id":"toolu_1","name":"weather","input":{"city":"Paris"}}],
+			"stop_reason":"tool_use","usage":{"input_tokens":12,"output_tokens":30}}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"claude-sonnet-4","stream":false,"think":true,"messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Weather in Paris?"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	cfg := testConfig("")
+	cfg.ReasoningModels = []string{"claude-*"}
+	ChatHandler(rr, req, cfg, testRegistry(t, anthropicBackend(server.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	if received.System != "Be brief." || received.MaxTokens != 1000+4096 || received.Thinking == nil {
+		t.Errorf("Unexpected Anthropic request: %+v", received)
+	}
+	var resp models.OllamaChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Message.Content != "Let me check." || resp.Message.Thinking != "Paris, obviously." {
+		t.Errorf("Unexpected message: %+v", resp.Message)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].Function.Name != "weather" || string(resp.Message.ToolCalls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool calls: %+v", resp.Message.ToolCalls)
+	}
+	if resp.OllamaMetrics == nil || resp.PromptEvalCount != 12 || resp.EvalCount != 30 || resp.DoneReason != "stop" {
+		t.Errorf("Unexpected metrics: %+v", resp.OllamaMetrics)
+	}
+}
+
+func TestChatHandler_AnthropicStreaming(t *testing.T) {
+	events := []string{
+		`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","role":"assistant","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`event: ping` + "\n" + `data: {"type":"ping"}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+		`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":0}`,
+		`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"weather","input":{}}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`event: content_block_stop` + "\n" + `data: {"type":"content_block_stop","index":1}`,
+		`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":25}}`,
+		`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var received models.AnthropicMessagesRequest
+		json.NewDecoder(r.Body).Decode(&received)
+		if !received.Stream {
+			t.Error("Expected a streaming request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			io.WriteString(w, event+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	body := `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(""), testRegistry(t, anthropicBackend(server.URL)))
+
+	var chunks []models.OllamaStreamChunk
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var chunk models.OllamaStreamChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("Invalid chunk %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	var content strings.Builder
+	var calls []models.OllamaToolCall
+	for _, chunk := range chunks {
+		content.WriteString(chunk.Message.Content)
+		calls = append(calls, chunk.Message.ToolCalls...)
+	}
+	if content.String() != "Hello there" {
+		t.Errorf("Got content %q", content.String())
+	}
+	if len(calls) != 1 || calls[0].ID != "toolu_1" || string(calls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool calls: %+v", calls)
+	}
+	last := chunks[len(chunks)-1]
+	if !last.Done || last.OllamaMetrics == nil || last.PromptEvalCount != 12 || last.EvalCount != 25 {
+		t.Errorf("Unexpected final chunk: %+v", last)
+	}
+}
+
+func TestGenerateHandler_AnthropicCompletionsNotSupported(t *testing.T) {
+	body := `{"model":"claude-sonnet-4","prompt":"Hi","raw":true,"stream":false}`
+	req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig(""), testRegistry(t, anthropicBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusNotImplemented || !strings.Contains(rr.Body.String(), "does not support /v1/completions") {
+		t.Errorf("Expected 501 for a raw prompt, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetModelsHandler_Anthropic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "sk-ant" {
+			t.Errorf("Unexpected key %q", r.Header.Get("x-api-key"))
+		}
+		io.WriteString(w, `{"data":[{"type":"model","id":"claude-sonnet-4","display_name":"Claude Sonnet 4","created_at":"2025-05-22T00:00:00Z"}],"has_more":false}`)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, testRegistry(t, anthropicBackend(server.URL)))
+
+	var resp models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Models) != 1 || resp.Models[0].Name != "claude-sonnet-4" || resp.Models[0].ModifiedAt != "2025-05-22T00:00:00Z" {
+		t.Errorf("Unexpected models: %+v", resp.Models)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// Azure OpenAI speaks the OpenAI API, but serves each model from a deployment
+// with its own URL: /v1/chat/completions becomes
+// /openai/deployments/{deployment}/chat/completions?api-version=...
+
+// azureURL returns the URL of the OpenAI endpoint path on the deployment
+// serving model.
+func azureURL(backend *backends.Backend, model, path string) string {
+	return backend.BaseURL + "/openai/deployments/" + url.PathEscape(backend.Deployment(model)) +
+		strings.TrimPrefix(path, "/v1") + "?api-version=" + url.QueryEscape(backend.APIVersion)
+}
+
+// azureModels lists the models of an Azure OpenAI backend: those mapped to a
+// deployment and the exact names among its models. Azure can only list base
+// models, not which of them are deployed, so the list comes from the
+// configuration.
+func azureModels(backend *backends.Backend) []models.OpenAIModel {
+	names := make(map[string]bool)
+	for model := range backend.Deployments {
+		names[model] = true
+	}
+	for _, model := range backend.Models {
+		if !strings.ContainsAny(model, `*?[\`) {
+			names[model] = true
+		}
+	}
+
+	azureModels := make([]models.OpenAIModel, 0, len(names))
+	for name := range names {
+		azureModels = append(azureModels, models.OpenAIModel{ID: name, Object: "model", OwnedBy: "azure"})
+	}
+	sort.Slice(azureModels, func(i, j int) bool { return azureModels[i].ID < azureModels[j].ID })
+	return azureModels
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// azureBackend serves gpt-4o and text-embedding-3-small from deployments of
+// an Azure OpenAI resource at url.
+func azureBackend(url string) config.BackendConfig {
+	return config.BackendConfig{
+		Name:        "azure",
+		BaseURL:     url,
+		Type:        config.BackendTypeAzure,
+		APIKey:      "az-key",
+		APIVersion:  "2024-10-21",
+		Models:      []string{"gpt-4o", "text-embedding-3-small", "gpt-4.1-*"},
+		Deployments: map[string]string{"gpt-4o": "prod-gpt4o"},
+	}
+}
+
+func TestChatHandler_AzureDeployment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/prod-gpt4o/chat/completions" || r.URL.Query().Get("api-version") != "2024-10-21" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "az-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("Expected the key in api-key only, got headers %v", r.Header)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o-2024-08-06",
+			"choices":[{"index":0,"message":{"role":"assistant","content":"Hi!"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"gpt-4o","stream":false,"messages":[{"role":"user","content":"Hello"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer client-key")
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(""), testRegistry(t, azureBackend(server.URL)))
+
+	var resp models.OllamaChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || resp.Message.Content != "Hi!" || resp.Model != "gpt-4o" {
+		t.Errorf("Unexpected response %d: %+v", rr.Code, resp)
+	}
+}
+
+func TestEmbedHandler_AzureDeploymentOfSameName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/text-embedding-3-small/embeddings" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		io.WriteString(w, `{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1,0.2]}],
+			"model":"text-embedding-3-small","usage":{"prompt_tokens":2,"total_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"text-embedding-3-small","input":"Hello"}`
+	req, _ := http.NewRequest("POST", "/api/embed", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	EmbedHandler(rr, req, testRegistry(t, azureBackend(server.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGetModelsHandler_AzureDeployments(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, testRegistry(t, azureBackend("http://dummyurl")))
+
+	var resp models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	var names []string
+	for _, model := range resp.Models {
+		names = append(names, model.Name)
+	}
+	// Patterns can't be listed
+	if strings.Join(names, ",") != "gpt-4o,text-embedding-3-small" {
+		t.Errorf("Unexpected models: %v", names)
+	}
+}
@@ -1,35 +1,27 @@
 package handlers
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
-
 	"strings"
 	"time"
 
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
 	"ollama-openai-proxy/src/models"
 )
 
-// ChatHandler signature changed
-func ChatHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL string) {
+// ChatHandler handles requests to /api/chat.
+// The request is sent to the backend serving the requested model.
+func ChatHandler(w http.ResponseWriter, r *http.Request, cfg config.AppConfig, registry *backends.Registry) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	// openAIBaseURL is now passed as an argument
-	apiURL := openAIBaseURL + "/v1/chat/completions"
-
-	// (Re-inserting the full ChatHandler logic with modification for openAIBaseURL)
-	authToken := r.Header.Get("Authorization")
-	if authToken == "" {
-		http.Error(w, "Unauthorized: Missing Authorization header", http.StatusUnauthorized)
-		return
-	}
+	timer := newRequestTimer()
 
 	var ollamaReq models.OllamaChatRequest
 	// Read r.Body once
@@ -45,146 +37,99 @@ func ChatHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL string) {
 		return
 	}
 
-	openAIReq := models.OpenAIChatRequest{
-		Model:    ollamaReq.Model,
-		Messages: make([]models.OpenAIChatMessage, len(ollamaReq.Messages)),
-		Stream:   ollamaReq.Stream,
-	}
-	for i, msg := range ollamaReq.Messages {
-		openAIReq.Messages[i] = models.OpenAIChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+	if alias, ok := registry.Alias(ollamaReq.Model); ok {
+		applyChatAlias(&ollamaReq, alias)
 	}
 
-	reqBodyBytes, err := json.Marshal(openAIReq)
+	targets, ok := routeModel(w, registry, ollamaReq.Model, r.Header.Get("Authorization"))
+	if !ok {
+		return
+	}
+	model := targets[0].Model // The first choice, for model-specific handling
+	keepAlive, err := parseKeepAlive(ollamaReq.KeepAlive)
 	if err != nil {
-		log.Printf("Error marshalling OpenAI request: %v", err)
-		http.Error(w, "Failed to marshal OpenAI request", http.StatusInternalServerError)
+		http.Error(w, "Bad request: Invalid keep_alive: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	httpClient := &http.Client{}
-	httpReq, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(reqBodyBytes)) // apiURL uses the passed-in openAIBaseURL
+	samplingParams, err := translateOllamaOptions(ollamaReq.Options, cfg.MaxTokensField)
 	if err != nil {
-		log.Printf("Error creating request to OpenAI: %v", err)
-		http.Error(w, "Failed to create request to OpenAI", http.StatusInternalServerError)
+		http.Error(w, "Bad request: Invalid options: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	httpReq.Header.Set("Authorization", authToken)
-	httpReq.Header.Set("Content-Type", "application/json")
-	if ollamaReq.Stream {
-		httpReq.Header.Set("Accept", "text/event-stream")
-	}
 
-	resp, err := httpClient.Do(httpReq)
+	ollamaMessages, err := applyImagePolicy(ollamaReq.Messages, model, cfg)
 	if err != nil {
-		log.Printf("Error making request to OpenAI: %v", err)
-		http.Error(w, "Failed to communicate with OpenAI API", http.StatusInternalServerError)
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	openAIMessages, err := toOpenAIMessages(ollamaMessages)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid message: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Read the entire response body once
-	respBodyBytes, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		log.Printf("Error reading OpenAI response body: %v", readErr)
-		http.Error(w, "Failed to read response from OpenAI", http.StatusInternalServerError)
+	responseFormat, err := toResponseFormat(ollamaReq.Format)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid format: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("OpenAI API Error: Status %d, Body: %s", resp.StatusCode, string(respBodyBytes))
-		var errorResp map[string]interface{}
-		if json.Unmarshal(respBodyBytes, &errorResp) == nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(resp.StatusCode)
-			json.NewEncoder(w).Encode(errorResp)
-			return
-		}
-		http.Error(w, "OpenAI API request failed: "+resp.Status, resp.StatusCode)
+	think, effort, err := parseThink(ollamaReq.Think)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid think: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	openAIReq := models.OpenAIChatRequest{
+		Messages: openAIMessages,
+		Tools:    toOpenAITools(ollamaReq.Tools),
+		Stream:   ollamaReq.Stream,
+
+		ResponseFormat:       responseFormat,
+		OpenAISamplingParams: samplingParams,
+	}
 	if ollamaReq.Stream {
-		w.Header().Set("Content-Type", "application/x-ndjson")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
-		// w.WriteHeader(http.StatusOK) // Implicitly called on first Write/Flush
-
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			log.Println("Streaming unsupported: Flusher not available")
-			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		openAIReq.StreamOptions = &models.OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	inFlight := registry.BeginRequest(ollamaReq.Model, keepAlive)
+	defer inFlight.End()
+	resp, err := postWithFailover(r.Context(), targets, r.Header.Get("Authorization"), "/v1/chat/completions", ollamaReq.Stream, func(target backends.Target) interface{} {
+		openAIReq.Model = target.Model
+		openAIReq.ReasoningEffort = reasoningEffort(target.Model, think, effort, cfg.ReasoningModels)
+		return openAIReq
+	})
+	if err != nil {
+		if clientDisconnected(r.Context(), ollamaReq.Model) {
 			return
 		}
+		log.Printf("Error making request to OpenAI: %v", err)
+		http.Error(w, "Failed to communicate with OpenAI API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
 
-		scanner := bufio.NewScanner(bytes.NewReader(respBodyBytes)) // Use the already read body
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "data: ") {
-				jsonData := strings.TrimPrefix(line, "data: ")
-				if jsonData == "[DONE]" {
-					finalChunk := models.OllamaStreamChunk{
-						Model:     ollamaReq.Model,
-						CreatedAt: time.Now().UTC().Format(time.RFC3339),
-						Message:   models.OllamaChatMessage{Role: "assistant", Content: ""},
-						Done:      true,
-					}
-					if err := json.NewEncoder(w).Encode(finalChunk); err != nil {
-						log.Printf("Error encoding final stream chunk: %v", err)
-						// Connection might be closed, stop processing
-						return
-					}
-					if _, err := w.Write([]byte("\n")); err != nil {
-						log.Printf("Error writing newline after final chunk: %v", err)
-						return
-					}
-					flusher.Flush()
-					break // Exit loop after [DONE]
-				}
-
-				var openAIChunk models.OpenAIStreamChunk
-				if err := json.Unmarshal([]byte(jsonData), &openAIChunk); err != nil {
-					log.Printf("Error unmarshalling OpenAI stream chunk '%s': %v", jsonData, err)
-					continue // Skip malformed chunk
-				}
-
-				// Process valid chunks that have content or role
-				if len(openAIChunk.Choices) > 0 && (openAIChunk.Choices[0].Delta.Content != "" || openAIChunk.Choices[0].Delta.Role != "") {
-					ollamaChunk := models.OllamaStreamChunk{
-						Model:     openAIChunk.Model,
-						CreatedAt: time.Now().UTC().Format(time.RFC3339),
-						Message: models.OllamaChatMessage{
-							Role:    openAIChunk.Choices[0].Delta.Role, // Use role from delta
-							Content: openAIChunk.Choices[0].Delta.Content,
-						},
-						Done: false,
-					}
-					if ollamaChunk.Message.Role == "" {
-						ollamaChunk.Message.Role = "assistant" // Default role if not in delta
-					}
-					if ollamaChunk.Model == "" { // Fallback if model not in chunk
-						ollamaChunk.Model = ollamaReq.Model
-					}
+	if resp.StatusCode != http.StatusOK {
+		forwardOpenAIError(w, resp)
+		return
+	}
+	inFlight.Accept()
 
-					if err := json.NewEncoder(w).Encode(ollamaChunk); err != nil {
-						log.Printf("Error encoding Ollama stream chunk: %v", err)
-						return // Stop streaming if encode fails
-					}
-					if _, err := w.Write([]byte("\n")); err != nil {
-						log.Printf("Error writing newline: %v", err)
-						return // Stop streaming if write fails
-					}
-					flusher.Flush()
-				}
+	if ollamaReq.Stream {
+		thinking := newThinkingSplitter(think, cfg.ThinkTagStrategy)
+		streamChatResponse(r.Context(), w, resp.Body, ollamaReq.Model, formatToValidate(ollamaReq.Format, cfg.ValidateStructuredOutput), thinking, timer)
+	} else { // Non-streaming
+		respBodyBytes, readErr := io.ReadAll(resp.Body)
+		if readErr != nil {
+			if clientDisconnected(r.Context(), ollamaReq.Model) {
+				return
 			}
-		}
-		if err := scanner.Err(); err != nil {
-			log.Printf("Error reading stream from OpenAI: %v", err)
+			log.Printf("Error reading OpenAI response body: %v", readErr)
+			http.Error(w, "Failed to read response from OpenAI", http.StatusInternalServerError)
+			return
 		}
 
-	} else { // Non-streaming
 		var openAIResp models.OpenAIChatResponse
 		if err := json.Unmarshal(respBodyBytes, &openAIResp); err != nil {
 			log.Printf("Error unmarshalling OpenAI non-stream response: %v. Body: %s", err, string(respBodyBytes))
@@ -198,17 +143,33 @@ func ChatHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL string) {
 			return
 		}
 
+		message := openAIResp.Choices[0].Message
+		thinking := newThinkingSplitter(think, cfg.ThinkTagStrategy)
+		reasoning, content := thinking.split(reasoningText(message), message.Content)
+		restReasoning, restContent := thinking.flush()
+
 		ollamaResp := models.OllamaChatResponse{
-			Model:     openAIResp.Model,
+			Model:     ollamaReq.Model, // The name the client asked for, which may carry a backend prefix
 			CreatedAt: time.Unix(openAIResp.Created, 0).UTC().Format(time.RFC3339),
 			Message: models.OllamaChatMessage{
-				Role:    "assistant", // Default role for response
-				Content: openAIResp.Choices[0].Message.Content,
+				Role:      "assistant", // Default role for response
+				Content:   content + restContent,
+				Thinking:  reasoning + restReasoning,
+				ToolCalls: toOllamaToolCalls(message.ToolCalls),
 			},
-			Done: true,
+			Done:          true,
+			OllamaMetrics: timer.metrics(openAIResp.Usage, openAIResp.Choices[0].FinishReason),
 		}
-		if openAIResp.Choices[0].Message.Role != "" {
-			ollamaResp.Message.Role = openAIResp.Choices[0].Message.Role
+		if message.Role != "" {
+			ollamaResp.Message.Role = message.Role
+		}
+
+		if format := formatToValidate(ollamaReq.Format, cfg.ValidateStructuredOutput); format != nil {
+			if err := checkFormat(format, ollamaResp.Message.Content); err != nil {
+				log.Printf("Structured output validation failed for %s: %v", ollamaReq.Model, err)
+				writeOllamaError(w, http.StatusBadGateway, formatMismatchMessage(err))
+				return
+			}
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -218,3 +179,139 @@ func ChatHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL string) {
 		}
 	}
 }
+
+// streamChatResponse converts the OpenAI SSE stream into Ollama NDJSON chunks
+// as events arrive, flushing after every chunk. When format is set, the full
+// content is validated against it and a mismatch ends the stream with an error
+// line instead of the final chunk, which carries the usage and timings.
+// Reasoning is sent in the `thinking` field of separate chunks. Cancelling ctx
+// aborts the upstream stream.
+func streamChatResponse(ctx context.Context, w http.ResponseWriter, body io.Reader, model string, format json.RawMessage, thinking *thinkingSplitter, timer *requestTimer) {
+	flusher, ok := startNDJSONStream(w)
+	if !ok {
+		return
+	}
+
+	var toolCalls toolCallAccumulator
+	var content strings.Builder
+	var usage *models.OpenAIUsage
+	var finishReason string
+	events := newSSEReader(body)
+	for {
+		event, err := events.Next()
+		if err != nil {
+			if err != io.EOF && !clientDisconnected(ctx, model) {
+				log.Printf("Error reading stream from OpenAI: %v", err)
+			}
+			return
+		}
+
+		if event.Data == "[DONE]" {
+			if reasoning, answer := thinking.flush(); reasoning != "" || answer != "" {
+				content.WriteString(answer)
+				if err := writeNDJSON(w, flusher, models.OllamaStreamChunk{
+					Model:     model,
+					CreatedAt: time.Now().UTC().Format(time.RFC3339),
+					Message:   models.OllamaChatMessage{Role: "assistant", Content: answer, Thinking: reasoning},
+				}); err != nil {
+					log.Printf("Error writing Ollama stream chunk: %v", err)
+					return
+				}
+			}
+			if calls := toolCalls.flush(); len(calls) > 0 {
+				if err := writeToolCallsChunk(w, flusher, model, calls); err != nil {
+					log.Printf("Error writing tool calls chunk: %v", err)
+					return
+				}
+			}
+			if format != nil {
+				if err := checkFormat(format, content.String()); err != nil {
+					log.Printf("Structured output validation failed for %s: %v", model, err)
+					if err := writeNDJSON(w, flusher, models.OllamaErrorResponse{Error: formatMismatchMessage(err)}); err != nil {
+						log.Printf("Error writing stream error: %v", err)
+					}
+					return
+				}
+			}
+			finalChunk := models.OllamaStreamChunk{
+				Model:     model,
+				CreatedAt: time.Now().UTC().Format(time.RFC3339),
+				Message:   models.OllamaChatMessage{Role: "assistant", Content: ""},
+				Done:      true,
+
+				OllamaMetrics: timer.metrics(usage, finishReason),
+			}
+			if err := writeNDJSON(w, flusher, finalChunk); err != nil {
+				log.Printf("Error writing final stream chunk: %v", err)
+			}
+			return // Exit after [DONE]
+		}
+
+		var openAIChunk models.OpenAIStreamChunk
+		if err := json.Unmarshal([]byte(event.Data), &openAIChunk); err != nil {
+			log.Printf("Error unmarshalling OpenAI stream chunk '%s': %v", event.Data, err)
+			continue // Skip malformed chunk
+		}
+		if openAIChunk.Usage != nil {
+			usage = openAIChunk.Usage
+		}
+		if len(openAIChunk.Choices) == 0 {
+			continue // e.g. the usage chunk
+		}
+		choice := openAIChunk.Choices[0]
+		toolCalls.add(choice.Delta.ToolCalls)
+		reasoning, answer := thinking.split(reasoningText(choice.Delta), choice.Delta.Content)
+		content.WriteString(answer)
+		if choice.Delta.Content != "" || reasoningText(choice.Delta) != "" || len(choice.Delta.ToolCalls) > 0 {
+			timer.token()
+		}
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+
+		// Process valid chunks that have content, thinking or role
+		if answer != "" || reasoning != "" || (choice.Delta.Role != "" && len(choice.Delta.ToolCalls) == 0) {
+			ollamaChunk := models.OllamaStreamChunk{
+				Model:     model,
+				CreatedAt: time.Now().UTC().Format(time.RFC3339),
+				Message: models.OllamaChatMessage{
+					Role:     choice.Delta.Role, // Use role from delta
+					Content:  answer,
+					Thinking: reasoning,
+				},
+				Done: false,
+			}
+			if ollamaChunk.Message.Role == "" {
+				ollamaChunk.Message.Role = "assistant" // Default role if not in delta
+			}
+
+			if err := writeNDJSON(w, flusher, ollamaChunk); err != nil {
+				if !clientDisconnected(ctx, model) {
+					log.Printf("Error writing Ollama stream chunk: %v", err)
+				}
+				return // Stop streaming if the client is gone
+			}
+		}
+
+		// Ollama clients expect complete tool calls, so they are only sent
+		// once the model has finished producing them.
+		if choice.FinishReason != "" {
+			if calls := toolCalls.flush(); len(calls) > 0 {
+				if err := writeToolCallsChunk(w, flusher, model, calls); err != nil {
+					log.Printf("Error writing tool calls chunk: %v", err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeToolCallsChunk sends reassembled tool calls as a single Ollama chunk.
+func writeToolCallsChunk(w http.ResponseWriter, flusher http.Flusher, model string, calls []models.OllamaToolCall) error {
+	return writeNDJSON(w, flusher, models.OllamaStreamChunk{
+		Model:     model,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Message:   models.OllamaChatMessage{Role: "assistant", Content: "", ToolCalls: calls},
+		Done:      false,
+	})
+}
@@ -3,17 +3,46 @@ package handlers
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
 	"ollama-openai-proxy/src/models" // Adjust if your module path is different
 	"strings"
 	"testing"
 	"time"
 )
 
+// testConfig returns the configuration handlers are tested with.
+func testConfig(openAIBaseURL string) config.AppConfig {
+	return config.AppConfig{OpenAIBaseURL: openAIBaseURL, MaxTokensField: "max_tokens"}
+}
+
+// testRegistry routes requests to backend, with the given aliases.
+func testRegistry(t *testing.T, backend config.BackendConfig, aliases ...config.AliasConfig) *backends.Registry {
+	t.Helper()
+	registry, err := backends.NewRegistry(config.AppConfig{Backends: []config.BackendConfig{backend}, Aliases: aliases})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+// openAIBackend serves all models, or the given ones, from the OpenAI
+// compatible API at openAIBaseURL.
+func openAIBackend(openAIBaseURL string, models ...string) config.BackendConfig {
+	return config.BackendConfig{
+		Name:     "openai",
+		BaseURL:  openAIBaseURL,
+		Models:   models,
+		Upstream: config.UpstreamConfig{Timeout: 10 * time.Second},
+	}
+}
+
 // --- NON-STREAMING TESTS ---
 
 func TestChatHandler_NonStreaming_Success(t *testing.T) {
@@ -63,7 +92,7 @@ func TestChatHandler_NonStreaming_Success(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, mockOpenAIServer.URL)
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", status, http.StatusOK, rr.Body.String())
@@ -119,7 +148,7 @@ func TestChatHandler_NonStreaming_OpenAIError(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, mockOpenAIServer.URL)
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	if status := rr.Code; status != http.StatusUnauthorized {
 		t.Fatalf("Handler returned wrong status for OpenAI error: got %v want %v. Body: %s", status, http.StatusUnauthorized, rr.Body.String())
@@ -151,7 +180,7 @@ func TestChatHandler_MissingAuthHeader(t *testing.T) {
 	// No Authorization header
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, "http://dummyurl") // URL doesn't matter as auth check is first
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl"))) // URL doesn't matter as auth check is first
 
 	if status := rr.Code; status != http.StatusUnauthorized {
 		t.Errorf("Handler returned wrong status: got %v want %v. Body: %s", status, http.StatusUnauthorized, rr.Body.String())
@@ -167,7 +196,7 @@ func TestChatHandler_BadRequestBody(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, "http://dummyurl")
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("Handler returned wrong status: got %v want %v. Body: %s", status, http.StatusBadRequest, rr.Body.String())
@@ -182,7 +211,7 @@ func TestChatHandler_MethodNotAllowed(t *testing.T) {
 	req.Header.Set("Authorization", "Bearer testtoken")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, "http://dummyurl")
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
 
 	if status := rr.Code; status != http.StatusMethodNotAllowed {
 		t.Errorf("Handler returned wrong status: got %v want %v. Body: %s", status, http.StatusMethodNotAllowed, rr.Body.String())
@@ -252,7 +281,7 @@ func TestChatHandler_Streaming_Success(t *testing.T) {
 	req.Header.Set("Authorization", "Bearer testtoken")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, mockOpenAIServer.URL)
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Fatalf("Handler returned wrong status for streaming: got %v want %v. Body: %s", status, http.StatusOK, rr.Body.String())
@@ -355,7 +384,7 @@ func TestChatHandler_Streaming_OpenAIReturnsErrorImmediately(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
-	ChatHandler(rr, req, mockOpenAIServer.URL)
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	if status := rr.Code; status != http.StatusBadRequest { // Should match OpenAI's error code
 		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", status, http.StatusBadRequest, rr.Body.String())
@@ -416,7 +445,7 @@ func TestChatHandler_Streaming_OpenAIErrorAfterPartialStream_MalformedJSON(t *te
     req.Header.Set("Authorization", "Bearer testtoken")
 
     rr := httptest.NewRecorder()
-    ChatHandler(rr, req, mockOpenAIServer.URL)
+    ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
     if status := rr.Code; status != http.StatusOK { // Status OK because headers were already sent
         t.Errorf("Handler returned wrong status: got %v want %v", status, http.StatusOK)
@@ -455,3 +484,815 @@ func TestChatHandler_Streaming_OpenAIErrorAfterPartialStream_MalformedJSON(t *te
     // or verify that the stream does not contain a "DONE" message if OpenAI didn't send it.
     // For this test, confirming initial chunks are received is the main goal.
 }
+
+func TestChatHandler_Streaming_Incremental(t *testing.T) {
+	// The mock upstream sends one chunk and then blocks until the client has
+	// received it. A buffering proxy would never deliver it and the mock
+	// would time out.
+	firstReceived := make(chan struct{})
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-test","choices":[{"index":0,"delta":{"role":"assistant","content":"First"},"finish_reason":null}]}`+"\n\n")
+		flusher.Flush()
+
+		select {
+		case <-firstReceived:
+		case <-time.After(2 * time.Second):
+			t.Error("Mock OpenAI: client did not receive the first chunk before the stream finished")
+		}
+
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-test","choices":[{"index":0,"delta":{"content":" last"},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+		flusher.Flush()
+	}))
+	defer mockOpenAIServer.Close()
+
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ChatHandler(w, r, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+	}))
+	defer proxyServer.Close()
+
+	ollamaReqPayload := models.OllamaChatRequest{Model: "gpt-test", Messages: []models.OllamaChatMessage{{Role: "user", Content: "Hi"}}, Stream: true}
+	reqBytes, _ := json.Marshal(ollamaReqPayload)
+	req, _ := http.NewRequest("POST", proxyServer.URL+"/api/chat", bytes.NewBuffer(reqBytes))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request to proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	var chunks []models.OllamaStreamChunk
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var chunk models.OllamaStreamChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				t.Fatalf("Could not unmarshal stream chunk '%s': %v", string(line), err)
+			}
+			if len(chunks) == 0 {
+				close(firstReceived)
+			}
+			chunks = append(chunks, chunk)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 Ollama chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Message.Content != "First" || chunks[1].Message.Content != " last" || !chunks[2].Done {
+		t.Errorf("Unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestChatHandler_Streaming_MultiLineDataAndComments(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, ": OPENROUTER PROCESSING\n\n")
+		io.WriteString(w, "event: message\r\n")
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk",`+"\r\n")
+		io.WriteString(w, `data: "model":"gpt-test","choices":[{"index":0,"delta":{"content":"Split"},"finish_reason":null}]}`+"\r\n\r\n")
+		io.WriteString(w, ":keepalive\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	ollamaReqPayload := models.OllamaChatRequest{Model: "gpt-test", Messages: []models.OllamaChatMessage{{Role: "user", Content: "Hi"}}, Stream: true}
+	reqBytes, _ := json.Marshal(ollamaReqPayload)
+	req, _ := http.NewRequest("POST", "/api/chat", bytes.NewBuffer(reqBytes))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines without blank lines, got %d: %q", len(lines), rr.Body.String())
+	}
+	var chunk models.OllamaStreamChunk
+	if err := json.Unmarshal([]byte(lines[0]), &chunk); err != nil {
+		t.Fatalf("Could not unmarshal stream chunk '%s': %v", lines[0], err)
+	}
+	if chunk.Message.Content != "Split" {
+		t.Errorf("Expected content 'Split', got '%s'", chunk.Message.Content)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &chunk); err != nil || !chunk.Done {
+		t.Errorf("Expected final done chunk, got '%s' (err: %v)", lines[1], err)
+	}
+}
+
+func TestChatHandler_OptionsAreTranslated(t *testing.T) {
+	var rawRequest map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&rawRequest)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "ok"}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}],"options":{"temperature":0.2,"num_predict":64,"stop":["END"],"num_ctx":4096}}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.MaxTokensField = "max_completion_tokens"
+	ChatHandler(rr, req, cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if rawRequest["temperature"] != 0.2 || rawRequest["max_completion_tokens"] != float64(64) {
+		t.Errorf("Options not translated into upstream request: %+v", rawRequest)
+	}
+	if _, ok := rawRequest["max_tokens"]; ok {
+		t.Errorf("Expected max_tokens to be omitted, got %+v", rawRequest)
+	}
+	if _, ok := rawRequest["num_ctx"]; ok {
+		t.Errorf("Expected num_ctx to be dropped, got %+v", rawRequest)
+	}
+}
+
+func TestChatHandler_InvalidOptions(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}],"options":{"temperature":"warm"}}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "temperature") {
+		t.Errorf("Expected error to name the invalid option, got: %s", rr.Body.String())
+	}
+}
+
+func TestChatHandler_ToolsRoundTrip_NonStreaming(t *testing.T) {
+	var openAIReq models.OpenAIChatRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		io.WriteString(w, `{"id":"chatcmpl-1","model":"gpt-4o","created":1700000000,"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_abc","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`)
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","messages":[
+		{"role":"user","content":"Weather in Berlin and Rome?"},
+		{"role":"assistant","content":"","tool_calls":[
+			{"function":{"name":"get_weather","arguments":{"city":"Berlin"}}},
+			{"function":{"name":"get_time","arguments":{"city":"Rome"}}}]},
+		{"role":"tool","content":"12:00","tool_name":"get_time"},
+		{"role":"tool","content":"Sunny"}
+	],"tools":[{"type":"function","function":{"name":"get_weather","description":"Get the weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	// Request translation
+	if len(openAIReq.Tools) != 1 || openAIReq.Tools[0].Function.Name != "get_weather" || !strings.Contains(string(openAIReq.Tools[0].Function.Parameters), `"city"`) {
+		t.Errorf("Tools not forwarded: %+v", openAIReq.Tools)
+	}
+	assistant := openAIReq.Messages[1]
+	if len(assistant.ToolCalls) != 2 || assistant.ToolCalls[0].Function.Arguments != `{"city":"Berlin"}` || assistant.ToolCalls[0].ID == "" {
+		t.Fatalf("Assistant tool calls not translated: %+v", assistant.ToolCalls)
+	}
+	if openAIReq.Messages[2].ToolCallID != assistant.ToolCalls[1].ID {
+		t.Errorf("Named tool result should answer get_time: got %s want %s", openAIReq.Messages[2].ToolCallID, assistant.ToolCalls[1].ID)
+	}
+	if openAIReq.Messages[3].ToolCallID != assistant.ToolCalls[0].ID {
+		t.Errorf("Unnamed tool result should answer the remaining call: got %s want %s", openAIReq.Messages[3].ToolCallID, assistant.ToolCalls[0].ID)
+	}
+
+	// Response translation
+	var resp models.OllamaChatResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(resp.Message.ToolCalls) != 1 {
+		t.Fatalf("Expected 1 tool call, got %+v", resp.Message)
+	}
+	call := resp.Message.ToolCalls[0]
+	if call.Function.Name != "get_weather" || string(call.Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool call: %+v (arguments %s)", call, call.Function.Arguments)
+	}
+}
+
+func TestChatHandler_ToolCalls_Streaming(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"ci"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Paris\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`[DONE]`,
+		}
+		for _, chunk := range chunks {
+			io.WriteString(w, "data: "+chunk+"\n\n")
+		}
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Weather?"}],"tools":[{"type":"function","function":{"name":"get_weather"}}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a tool call chunk and a final chunk, got %d lines: %s", len(lines), rr.Body.String())
+	}
+	var chunk models.OllamaStreamChunk
+	if err := json.Unmarshal([]byte(lines[0]), &chunk); err != nil {
+		t.Fatalf("Could not unmarshal chunk: %v", err)
+	}
+	calls := chunk.Message.ToolCalls
+	if len(calls) != 2 || chunk.Done {
+		t.Fatalf("Expected 2 tool calls in a non-final chunk, got %+v", chunk)
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || string(calls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("First tool call not reassembled: %+v (arguments %s)", calls[0], calls[0].Function.Arguments)
+	}
+	if calls[1].Function.Name != "get_time" || calls[1].Function.Index != 1 {
+		t.Errorf("Unexpected second tool call: %+v", calls[1])
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &chunk); err != nil || !chunk.Done {
+		t.Errorf("Expected final done chunk, got %s", lines[1])
+	}
+}
+
+func TestChatHandler_ImagesBecomeContentParts(t *testing.T) {
+	var rawRequest struct {
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&rawRequest)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "A cat"}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	ollamaReqPayload := models.OllamaChatRequest{
+		Model: "gpt-4o",
+		Messages: []models.OllamaChatMessage{
+			{Role: "system", Content: "Describe images"},
+			{Role: "user", Content: "What is this?", Images: []string{testPNG}},
+		},
+	}
+	reqBytes, _ := json.Marshal(ollamaReqPayload)
+	req, _ := http.NewRequest("POST", "/api/chat", bytes.NewBuffer(reqBytes))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if string(rawRequest.Messages[0].Content) != `"Describe images"` {
+		t.Errorf("Expected text-only message to keep string content, got %s", rawRequest.Messages[0].Content)
+	}
+	var parts []models.OpenAIContentPart
+	if err := json.Unmarshal(rawRequest.Messages[1].Content, &parts); err != nil {
+		t.Fatalf("Expected content parts, got %s", rawRequest.Messages[1].Content)
+	}
+	if len(parts) != 2 || parts[0].Type != "text" || parts[0].Text != "What is this?" || parts[1].Type != "image_url" {
+		t.Fatalf("Unexpected content parts: %+v", parts)
+	}
+	if parts[1].ImageURL.URL != "data:image/png;base64,"+testPNG {
+		t.Errorf("Unexpected image URL: %s", parts[1].ImageURL.URL)
+	}
+}
+
+func TestChatHandler_ImagesForTextOnlyModel(t *testing.T) {
+	var openAIRequestBody string
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		openAIRequestBody = string(body)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "ok"}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	newRequest := func() *http.Request {
+		ollamaReqPayload := models.OllamaChatRequest{
+			Model:    "gpt-3.5-turbo",
+			Messages: []models.OllamaChatMessage{{Role: "user", Content: "What is this?", Images: []string{testPNG}}},
+		}
+		reqBytes, _ := json.Marshal(ollamaReqPayload)
+		req, _ := http.NewRequest("POST", "/api/chat", bytes.NewBuffer(reqBytes))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		return req
+	}
+
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.TextOnlyModels = []string{"gpt-3.5-*"}
+
+	cfg.TextOnlyImagePolicy = "reject"
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, newRequest(), cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "does not support images") {
+		t.Errorf("Expected images to be rejected, got %v: %s", rr.Code, rr.Body.String())
+	}
+	if openAIRequestBody != "" {
+		t.Errorf("Rejected request should not reach OpenAI")
+	}
+
+	cfg.TextOnlyImagePolicy = "strip"
+	rr = httptest.NewRecorder()
+	ChatHandler(rr, newRequest(), cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if strings.Contains(openAIRequestBody, "image_url") || !strings.Contains(openAIRequestBody, `"content":"What is this?"`) {
+		t.Errorf("Expected images to be stripped, got %s", openAIRequestBody)
+	}
+}
+
+func TestChatHandler_InvalidImage(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi","images":["%%%"]}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestChatHandler_FormatSchema(t *testing.T) {
+	var openAIReq map[string]interface{}
+	answer := `{"name":"Ada"}`
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: answer}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Who?"}],"format":{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}}`
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		return req
+	}
+
+	// Without validation the response is passed through
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, newRequest(), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	responseFormat, _ := openAIReq["response_format"].(map[string]interface{})
+	jsonSchema, _ := responseFormat["json_schema"].(map[string]interface{})
+	if responseFormat["type"] != "json_schema" || jsonSchema["schema"] == nil {
+		t.Errorf("Unexpected response_format: %+v", openAIReq["response_format"])
+	}
+
+	// With validation the missing "age" is reported
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.ValidateStructuredOutput = true
+	rr = httptest.NewRecorder()
+	ChatHandler(rr, newRequest(), cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusBadGateway, rr.Body.String())
+	}
+	var errResp models.OllamaErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&errResp); err != nil || !strings.Contains(errResp.Error, `missing required property "age"`) {
+		t.Errorf("Expected Ollama error about the missing property, got %+v (err: %v)", errResp, err)
+	}
+
+	answer = `{"name":"Ada","age":36}`
+	rr = httptest.NewRecorder()
+	ChatHandler(rr, newRequest(), cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected valid output to pass validation, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestChatHandler_FormatJSON_StreamingValidation(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"{\"a\":"}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","stream":true,"format":"json","messages":[{"role":"user","content":"JSON please"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.ValidateStructuredOutput = true
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+
+	if responseFormat, _ := openAIReq["response_format"].(map[string]interface{}); responseFormat["type"] != "json_object" {
+		t.Errorf("Expected json_object response format, got %+v", openAIReq["response_format"])
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a content chunk and an error line, got: %s", rr.Body.String())
+	}
+	var errResp models.OllamaErrorResponse
+	if err := json.Unmarshal([]byte(lines[1]), &errResp); err != nil || !strings.Contains(errResp.Error, "not valid JSON") {
+		t.Errorf("Expected error line about invalid JSON, got %s", lines[1])
+	}
+}
+
+func TestChatHandler_InvalidFormat(t *testing.T) {
+	body := `{"model":"gpt-4o","format":"xml","messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestChatHandler_StreamingMetrics(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if streamOptions, _ := openAIReq["stream_options"].(map[string]interface{}); streamOptions["include_usage"] != true {
+		t.Errorf("Expected stream_options.include_usage to be requested, got %+v", openAIReq["stream_options"])
+	}
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	var first, last map[string]interface{}
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+	if _, ok := first["eval_count"]; ok {
+		t.Errorf("Expected no metrics on intermediate chunks, got %s", lines[0])
+	}
+	if last["done"] != true || last["done_reason"] != "length" {
+		t.Errorf("Expected final chunk with done_reason length, got %s", lines[len(lines)-1])
+	}
+	if last["prompt_eval_count"] != float64(12) || last["eval_count"] != float64(7) {
+		t.Errorf("Expected token counts from usage, got %s", lines[len(lines)-1])
+	}
+	for _, field := range []string{"total_duration", "load_duration", "prompt_eval_duration", "eval_duration"} {
+		if _, ok := last[field].(float64); !ok {
+			t.Errorf("Expected %s in final chunk, got %s", field, lines[len(lines)-1])
+		}
+	}
+	if last["total_duration"].(float64) <= 0 {
+		t.Errorf("Expected a positive total_duration, got %v", last["total_duration"])
+	}
+}
+
+func TestChatHandler_NonStreamingMetrics(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		io.WriteString(w, `{"model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":3,"total_tokens":8}}`)
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hello"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if _, ok := openAIReq["stream_options"]; ok {
+		t.Errorf("stream_options must not be sent without streaming")
+	}
+	var resp models.OllamaChatResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || resp.OllamaMetrics == nil {
+		t.Fatalf("Expected metrics in response (err: %v)", err)
+	}
+	if resp.DoneReason != "stop" || resp.PromptEvalCount != 5 || resp.EvalCount != 3 {
+		t.Errorf("Unexpected metrics: %+v", *resp.OllamaMetrics)
+	}
+	if resp.TotalDuration <= 0 || resp.EvalDuration <= 0 {
+		t.Errorf("Expected measured durations, got %+v", *resp.OllamaMetrics)
+	}
+}
+
+func TestChatHandler_ThinkStreaming(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Two plus two"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"reasoning_content":" is four."}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"4"},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"o3-mini","stream":true,"think":"high","messages":[{"role":"user","content":"2+2?"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.ReasoningModels = []string{"o3*"}
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+
+	if openAIReq["reasoning_effort"] != "high" {
+		t.Errorf("Expected reasoning_effort high, got %v", openAIReq["reasoning_effort"])
+	}
+
+	var thinking, content strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(rr.Body.String()), "\n") {
+		var chunk models.OllamaStreamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("Invalid chunk %s: %v", line, err)
+		}
+		thinking.WriteString(chunk.Message.Thinking)
+		content.WriteString(chunk.Message.Content)
+	}
+	if thinking.String() != "Two plus two is four." || content.String() != "4" {
+		t.Errorf("Got thinking %q and content %q", thinking.String(), content.String())
+	}
+}
+
+func TestChatHandler_ThinkTags(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "<think>\nSimple.\n</think>\n\n4"}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"deepseek-r1","think":true,"messages":[{"role":"user","content":"2+2?"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.ThinkTagStrategy = "split"
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+
+	if _, ok := openAIReq["reasoning_effort"]; ok {
+		t.Errorf("reasoning_effort must only be sent to reasoning models")
+	}
+	var resp models.OllamaChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Message.Thinking != "Simple." || resp.Message.Content != "4" {
+		t.Errorf("Got thinking %q and content %q", resp.Message.Thinking, resp.Message.Content)
+	}
+}
+
+func TestChatHandler_ClientDisconnectCancelsUpstream(t *testing.T) {
+	firstChunkSent := make(chan struct{})
+	upstreamClosed := make(chan struct{})
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // The server only notices a closed connection once the body is read
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Once upon"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+		close(firstChunkSent)
+
+		// Keep the stream open until the proxy goes away
+		select {
+		case <-r.Context().Done():
+			close(upstreamClosed)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer mockOpenAIServer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Tell me a long story"}]}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	before := cancelledRequests.Load()
+	handlerDone := make(chan struct{})
+	rr := httptest.NewRecorder()
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL))
+	go func() {
+		ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), registry)
+		close(handlerDone)
+	}()
+
+	<-firstChunkSent
+	cancel() // The user hits "stop"
+
+	select {
+	case <-upstreamClosed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Upstream connection was not closed after the client disconnected")
+	}
+	select {
+	case <-handlerDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Handler did not return after the client disconnected")
+	}
+	if cancelledRequests.Load() != before+1 {
+		t.Errorf("Expected the cancelled request to be counted, got %d -> %d", before, cancelledRequests.Load())
+	}
+}
+
+func TestChatHandler_RoutesByModel(t *testing.T) {
+	// mockBackend answers with its name and records what it received
+	mockBackend := func(name string, gotModel, gotAuth *string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var openAIReq models.OpenAIChatRequest
+			json.NewDecoder(r.Body).Decode(&openAIReq)
+			*gotModel, *gotAuth = openAIReq.Model, r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"model":%q,"choices":[{"index":0,"message":{"role":"assistant","content":"from %s"},"finish_reason":"stop"}]}`, openAIReq.Model, name)
+		}))
+	}
+	var localModel, localAuth, cloudModel, cloudAuth string
+	local := mockBackend("local", &localModel, &localAuth)
+	defer local.Close()
+	cloud := mockBackend("cloud", &cloudModel, &cloudAuth)
+	defer cloud.Close()
+
+	registry, err := backends.NewRegistry(config.AppConfig{
+		PrefixModels: true,
+		Backends: []config.BackendConfig{
+			{Name: "local", BaseURL: local.URL, APIKey: "sk-local", Models: []string{"llama*"}},
+			{Name: "cloud", BaseURL: cloud.URL},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chat := func(model string) (int, models.OllamaChatResponse) {
+		body := `{"model":"` + model + `","stream":false,"messages":[{"role":"user","content":"Hi"}]}`
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		rr := httptest.NewRecorder()
+		ChatHandler(rr, req, testConfig(""), registry)
+		var resp models.OllamaChatResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+
+	if code, resp := chat("llama3"); code != http.StatusOK || resp.Message.Content != "from local" {
+		t.Errorf("Expected llama3 to go to the local backend, got %d %+v", code, resp)
+	}
+	if localModel != "llama3" || localAuth != "Bearer sk-local" {
+		t.Errorf("Local backend got model %q with %q", localModel, localAuth)
+	}
+
+	code, resp := chat("cloud/gpt-4o")
+	if code != http.StatusOK || resp.Message.Content != "from cloud" || resp.Model != "cloud/gpt-4o" {
+		t.Errorf("Expected cloud/gpt-4o to go to the cloud backend, got %d %+v", code, resp)
+	}
+	if cloudModel != "gpt-4o" || cloudAuth != "Bearer testtoken" {
+		t.Errorf("Cloud backend got model %q with %q", cloudModel, cloudAuth)
+	}
+
+	// The local backend only serves llama models
+	if code, _ := chat("local/gpt-4o"); code != http.StatusOK || cloudModel != "local/gpt-4o" {
+		t.Errorf("Expected local/gpt-4o to fall through to the catch-all backend, got %d", code)
+	}
+}
+
+func TestChatHandler_UnknownModel(t *testing.T) {
+	body := `{"model":"gpt-3.5-turbo","stream":false,"messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o")))
+
+	if rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), `model \"gpt-3.5-turbo\" not found`) {
+		t.Errorf("Expected 404 for a model no backend serves, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestChatHandler_FailsOverWithinGroup(t *testing.T) {
+	var primaryCalls int
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryCalls++
+		http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	var secondaryModel string
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var openAIReq models.OpenAIChatRequest
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		secondaryModel = openAIReq.Model
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer secondary.Close()
+
+	registry, err := backends.NewRegistry(config.AppConfig{
+		Backends: []config.BackendConfig{
+			{Name: "primary", BaseURL: primary.URL},
+			{Name: "secondary", BaseURL: secondary.URL},
+		},
+		Groups: []config.GroupConfig{{Name: "gpt-4o", Members: []config.MemberConfig{
+			{Backend: "primary", Model: "gpt-4o"},
+			{Backend: "secondary", Model: "gpt-4o-2024-08-06"},
+		}}},
+		Health: config.HealthConfig{MaxFailures: 1, Cooldown: config.Duration(time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chat := func() *httptest.ResponseRecorder {
+		body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+		req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		rr := httptest.NewRecorder()
+		ChatHandler(rr, req, testConfig(""), registry)
+		return rr
+	}
+
+	rr := chat()
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"content":"Hi"`) || !strings.Contains(rr.Body.String(), `"model":"gpt-4o"`) {
+		t.Fatalf("Expected the secondary backend to answer, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if primaryCalls != 1 || secondaryModel != "gpt-4o-2024-08-06" {
+		t.Errorf("Got %d primary calls and secondary model %q", primaryCalls, secondaryModel)
+	}
+
+	// The primary is ejected now and tried last
+	chat()
+	if primaryCalls != 1 {
+		t.Errorf("Expected the ejected primary to be skipped, got %d calls", primaryCalls)
+	}
+	if backend := registry.Backends()[1]; backend.Inflight() != 0 {
+		t.Errorf("Expected no requests in flight after the responses, got %d", backend.Inflight())
+	}
+}
+
+func TestChatHandler_AllGroupMembersFail(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{"error":{"message":"upstream down"}}`)
+	}))
+	defer failing.Close()
+
+	registry, err := backends.NewRegistry(config.AppConfig{
+		Backends: []config.BackendConfig{{Name: "a", BaseURL: failing.URL}, {Name: "b", BaseURL: failing.URL}},
+		Groups: []config.GroupConfig{{Name: "gpt-4o", Members: []config.MemberConfig{
+			{Backend: "a", Model: "gpt-4o"}, {Backend: "b", Model: "gpt-4o"},
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"model":"gpt-4o","stream":false,"messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(""), registry)
+
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "upstream down") {
+		t.Errorf("Expected the last upstream error, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
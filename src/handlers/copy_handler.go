@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// CopyHandler handles requests to /api/copy. The destination becomes an alias
+// with the definition of the source alias, or an alias of the source model
+// when that is served upstream, and is kept like models created with
+// /api/create.
+func CopyHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var copyReq models.OllamaCopyRequest
+	if err := json.NewDecoder(r.Body).Decode(&copyReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if copyReq.Source == "" || copyReq.Destination == "" {
+		writeOllamaError(w, http.StatusBadRequest, "source and destination are required")
+		return
+	}
+
+	aliasCfg := config.AliasConfig{Model: copyReq.Source}
+	if source, ok := registry.Alias(copyReq.Source); ok {
+		aliasCfg = source.Config()
+	}
+	aliasCfg.Name = copyReq.Destination
+	alias, err := registry.CreateAlias(aliasCfg)
+	if err != nil {
+		writeAliasError(w, "copying", copyReq.Destination, err)
+		return
+	}
+	log.Printf("Copied model %s to %s", copyReq.Source, alias.Name)
+	w.WriteHeader(http.StatusOK)
+}
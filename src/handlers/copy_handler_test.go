@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/models"
+)
+
+func TestCopyHandler(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.OpenAIModelsResponse{Object: "list", Data: []models.OpenAIModel{{ID: "gpt-4o", Object: "model", Created: 1700000000}}})
+	}))
+	defer mockOpenAIServer.Close()
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias())
+
+	copyModel := func(body string) int {
+		req, _ := http.NewRequest("POST", "/api/copy", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		CopyHandler(rr, req, registry)
+		return rr.Code
+	}
+
+	if code := copyModel(`{"source":"reviewer","destination":"my-reviewer"}`); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if alias, ok := registry.Alias("my-reviewer"); !ok || alias.Model != "gpt-4o" || alias.System != "You review code." {
+		t.Errorf("Expected the reviewer definition under the new name, got %+v", alias)
+	}
+	if code := copyModel(`{"source":"gpt-4o","destination":"gpt"}`); code != http.StatusOK {
+		t.Fatalf("Expected 200 for an upstream model, got %d", code)
+	}
+	if alias, ok := registry.Alias("gpt"); !ok || alias.Model != "gpt-4o" || alias.System != "" {
+		t.Errorf("Expected an alias of the upstream model, got %+v", alias)
+	}
+
+	// The copies are listed right away
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, registry)
+	var tags models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&tags)
+	var names []string
+	for _, model := range tags.Models {
+		names = append(names, model.Name)
+	}
+	if got := strings.Join(names, ","); got != "gpt-4o,gpt:latest,my-reviewer:latest,reviewer:latest" {
+		t.Errorf("Unexpected models: %s", got)
+	}
+
+	for body, want := range map[string]int{
+		`{"source":"claude-3","destination":"x"}`:      http.StatusNotFound,
+		`{"source":"gpt-4o","destination":"reviewer"}`: http.StatusBadRequest,
+		`{"source":"gpt-4o"}`:                          http.StatusBadRequest,
+	} {
+		if code := copyModel(body); code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, code)
+		}
+	}
+}
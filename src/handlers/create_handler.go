@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/modelfile"
+	"ollama-openai-proxy/src/models"
+)
+
+// CreateHandler handles requests to /api/create. There are no weights to
+// build a model from, so the new model becomes an alias of an upstream model
+// with the defaults of the Modelfile, see config.AliasConfig. Fields of the
+// request override the directives of its Modelfile. A FROM naming an alias
+// inherits that alias's model and defaults. A TEMPLATE is kept for /api/show
+// only, as upstream APIs format prompts themselves.
+func CreateHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var createReq models.OllamaCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&createReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	name := createReq.Model
+	if name == "" {
+		name = createReq.Name
+	}
+	if name == "" {
+		writeOllamaError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	if len(createReq.Files) > 0 || len(createReq.Adapters) > 0 || createReq.Quantize != "" {
+		writeOllamaError(w, http.StatusBadRequest, "models can only be created from upstream models: files, adapters and quantize are not supported")
+		return
+	}
+
+	progress := newProgressWriter(w, createReq.Stream == nil || *createReq.Stream)
+	definition := modelfile.Modelfile{Parameters: make(map[string]interface{})}
+	if createReq.Modelfile != "" {
+		progress.status("parsing modelfile")
+		parsed, err := modelfile.Parse(createReq.Modelfile)
+		if err != nil {
+			progress.fail(http.StatusBadRequest, "invalid modelfile: "+err.Error())
+			return
+		}
+		definition = *parsed
+	}
+	mergeCreateRequest(&definition, createReq)
+	if definition.From == "" {
+		progress.fail(http.StatusBadRequest, "neither 'from' nor a Modelfile FROM was given")
+		return
+	}
+
+	aliasCfg, err := createdAlias(registry, name, definition)
+	if err != nil {
+		progress.fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	progress.status("using upstream model " + aliasCfg.Model)
+	if aliasCfg.Template != "" {
+		progress.status("template is only kept for /api/show, upstream APIs format prompts themselves")
+	}
+	progress.status("writing manifest")
+	alias, err := registry.CreateAlias(aliasCfg)
+	if err != nil {
+		progress.fail(aliasErrorStatus("creating", name, err))
+		return
+	}
+	log.Printf("Created model %s from %s", alias.Name, alias.Model)
+	progress.status("success")
+}
+
+// progressWriter reports the steps of /api/create and /api/pull as they
+// start. Without streaming only the final status is sent. Once the stream has
+// started, failures can only be reported in it, as in Ollama.
+type progressWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	stream  bool
+	started bool
+}
+
+func newProgressWriter(w http.ResponseWriter, stream bool) *progressWriter {
+	flusher, _ := w.(http.Flusher)
+	return &progressWriter{w: w, flusher: flusher, stream: stream}
+}
+
+// status sends status, which is only sent without streaming when it is the
+// final "success".
+func (p *progressWriter) status(status string) {
+	if !p.stream && status != "success" {
+		return
+	}
+	p.write(models.OllamaStatusResponse{Status: status})
+}
+
+// fail answers with an error status, or an error line once streaming.
+func (p *progressWriter) fail(code int, message string) {
+	if !p.started {
+		writeOllamaError(p.w, code, message)
+		return
+	}
+	p.write(models.OllamaErrorResponse{Error: message})
+}
+
+func (p *progressWriter) write(v interface{}) {
+	if !p.started {
+		p.started = true
+		if p.stream {
+			p.w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			p.w.Header().Set("Content-Type", "application/json")
+		}
+	}
+	if err := json.NewEncoder(p.w).Encode(v); err != nil {
+		log.Printf("Error encoding progress: %v", err)
+		return
+	}
+	if p.flusher != nil {
+		p.flusher.Flush()
+	}
+}
+
+// mergeCreateRequest lets the fields of createReq override the directives of
+// its Modelfile.
+func mergeCreateRequest(definition *modelfile.Modelfile, createReq models.OllamaCreateRequest) {
+	if createReq.From != "" {
+		definition.From = createReq.From
+	}
+	if createReq.System != "" {
+		definition.System = createReq.System
+	}
+	if createReq.Template != "" {
+		definition.Template = createReq.Template
+	}
+	for key, value := range createReq.Parameters {
+		definition.Parameters[key] = value
+	}
+	if len(createReq.Messages) > 0 {
+		definition.Messages = nil
+		for _, msg := range createReq.Messages {
+			definition.Messages = append(definition.Messages, modelfile.Message{Role: msg.Role, Content: msg.Content})
+		}
+	}
+}
+
+// createdAlias turns a model definition into an alias called name. When FROM
+// names an alias, the definition is laid over that alias's defaults.
+func createdAlias(registry *backends.Registry, name string, definition modelfile.Modelfile) (config.AliasConfig, error) {
+	// A backend without a model list would take any name for a model
+	if isLocalModel(definition.From) {
+		return config.AliasConfig{}, fmt.Errorf("FROM %s names local weights: models can only be created from upstream models", definition.From)
+	}
+	aliasCfg := config.AliasConfig{Model: definition.From}
+	if base, ok := registry.Alias(definition.From); ok {
+		aliasCfg = base.Config()
+	}
+	aliasCfg.Name = name
+
+	if definition.System != "" {
+		aliasCfg.System = definition.System
+	}
+	if definition.Template != "" {
+		aliasCfg.Template = definition.Template
+	}
+	if len(definition.Parameters) > 0 {
+		options := make(map[string]interface{}, len(aliasCfg.Options)+len(definition.Parameters))
+		for key, value := range aliasCfg.Options {
+			options[key] = value
+		}
+		for key, value := range definition.Parameters {
+			options[key] = value
+		}
+		aliasCfg.Options = options
+	}
+	if _, err := translateOllamaOptions(aliasCfg.Options, "max_tokens"); err != nil {
+		return config.AliasConfig{}, fmt.Errorf("invalid parameters: %w", err)
+	}
+	if len(definition.Messages) > 0 {
+		aliasCfg.Messages = nil
+		for _, msg := range definition.Messages {
+			if !config.ValidMessageRole(msg.Role) {
+				return config.AliasConfig{}, fmt.Errorf("unsupported message role %q", msg.Role)
+			}
+			aliasCfg.Messages = append(aliasCfg.Messages, config.AliasMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return aliasCfg, nil
+}
+
+// isLocalModel reports whether from names a file or blob rather than a model.
+func isLocalModel(from string) bool {
+	lower := strings.ToLower(from)
+	return strings.HasPrefix(from, ".") || strings.HasPrefix(from, "/") || strings.HasPrefix(from, "~") ||
+		strings.Contains(lower, "@sha256:") || strings.HasSuffix(lower, ".gguf") || strings.HasSuffix(lower, ".safetensors")
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/models"
+)
+
+// create sends body to CreateHandler and returns the status code and the
+// streamed statuses, or the error.
+func create(t *testing.T, handler http.HandlerFunc, body string) (int, []string) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", "/api/create", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	var lines []string
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var line struct{ Status, Error string }
+		json.Unmarshal(scanner.Bytes(), &line)
+		lines = append(lines, line.Status+line.Error)
+	}
+	return rr.Code, lines
+}
+
+func TestCreateHandler_Modelfile(t *testing.T) {
+	registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"), reviewerAlias())
+	handler := func(w http.ResponseWriter, r *http.Request) { CreateHandler(w, r, registry) }
+
+	modelfile := "FROM gpt-4o\nSYSTEM \"\"\"You write tests.\"\"\"\nPARAMETER temperature 0.4\nPARAMETER stop END\nMESSAGE user Hi\n"
+	body, _ := json.Marshal(map[string]string{"name": "tester", "modelfile": modelfile})
+	code, statuses := create(t, handler, string(body))
+	if code != http.StatusOK || strings.Join(statuses, "|") != "parsing modelfile|using upstream model gpt-4o|writing manifest|success" {
+		t.Fatalf("Unexpected answer: %d %v", code, statuses)
+	}
+	alias, ok := registry.Alias("tester")
+	if !ok || alias.Model != "gpt-4o" || alias.System != "You write tests." || alias.Options["temperature"] != 0.4 || len(alias.Messages) != 1 {
+		t.Errorf("Unexpected alias: %+v", alias)
+	}
+}
+
+func TestCreateHandler_FromWithModelfile(t *testing.T) {
+	registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"), reviewerAlias())
+	handler := func(w http.ResponseWriter, r *http.Request) { CreateHandler(w, r, registry) }
+
+	code, statuses := create(t, handler, `{"model":"greeter","from":"gpt-4o","modelfile":"SYSTEM hi"}`)
+	if code != http.StatusOK || statuses[len(statuses)-1] != "success" {
+		t.Fatalf("Unexpected answer: %d %v", code, statuses)
+	}
+	if alias, ok := registry.Alias("greeter"); !ok || alias.Model != "gpt-4o" || alias.System != "hi" {
+		t.Errorf("Unexpected alias: %+v", alias)
+	}
+}
+
+func TestCreateHandler_RejectsLocalWeights(t *testing.T) {
+	// The backend serves any model name
+	registry := testRegistry(t, openAIBackend("http://dummyurl"))
+	handler := func(w http.ResponseWriter, r *http.Request) { CreateHandler(w, r, registry) }
+	for _, from := range []string{"./model.gguf", "/models/llama", "~/llama", "llama3@sha256:abc", "model.safetensors"} {
+		body, _ := json.Marshal(map[string]interface{}{"model": "local", "from": from, "stream": false})
+		if code, lines := create(t, handler, string(body)); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for FROM %s, got %d: %v", from, code, lines)
+		}
+	}
+	if _, ok := registry.Alias("local"); ok {
+		t.Error("Expected no model to be created")
+	}
+}
+
+func TestCreateHandler_FromAlias(t *testing.T) {
+	var openAIReq models.OpenAIChatRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "{}"}, FinishReason: "stop"}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias())
+	handler := func(w http.ResponseWriter, r *http.Request) { CreateHandler(w, r, registry) }
+
+	body := `{"model":"strict-reviewer:v1","from":"reviewer","parameters":{"temperature":0},"stream":false}`
+	code, statuses := create(t, handler, body)
+	if code != http.StatusOK || len(statuses) != 1 || statuses[0] != "success" {
+		t.Fatalf("Unexpected answer: %d %v", code, statuses)
+	}
+	// The reviewer alias's model and defaults are inherited
+	alias, ok := registry.Alias("strict-reviewer:v1")
+	if !ok || alias.Model != "gpt-4o" || alias.System != "You review code." || alias.Options["temperature"] != float64(0) || alias.Options["num_predict"] != 256.0 {
+		t.Errorf("Unexpected alias: %+v", alias)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(`{"model":"strict-reviewer:v1","stream":false,"messages":[{"role":"user","content":"Hi"}]}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(mockOpenAIServer.URL), registry)
+	if rr.Code != http.StatusOK || openAIReq.Model != "gpt-4o" || openAIReq.Temperature == nil || *openAIReq.Temperature != 0 {
+		t.Errorf("Expected the created model to be served by gpt-4o, got %d %+v", rr.Code, openAIReq)
+	}
+}
+
+func TestCreateHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no name", `{"from":"gpt-4o"}`, http.StatusBadRequest},
+		{"no FROM", `{"model":"x","system":"hi"}`, http.StatusBadRequest},
+		{"invalid modelfile", `{"model":"x","modelfile":"FROM gpt-4o\nADAPTER lora.gguf"}`, http.StatusBadRequest},
+		{"files", `{"model":"x","files":{"model.gguf":"sha256:abc"}}`, http.StatusBadRequest},
+		{"invalid parameter", `{"model":"x","from":"gpt-4o","parameters":{"temperature":"hot"}}`, http.StatusBadRequest},
+		{"invalid role", `{"model":"x","from":"gpt-4o","messages":[{"role":"tool","content":"hi"}]}`, http.StatusBadRequest},
+		{"unknown model", `{"model":"x","from":"claude-3"}`, http.StatusNotFound},
+		{"configured alias", `{"model":"reviewer","from":"gpt-4o"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"), reviewerAlias())
+			handler := func(w http.ResponseWriter, r *http.Request) { CreateHandler(w, r, registry) }
+			code, lines := create(t, handler, strings.Replace(tt.body, "{", `{"stream":false,`, 1))
+			if code != tt.want {
+				t.Errorf("Expected %d, got %d: %v", tt.want, code, lines)
+			}
+			// Once streaming, failures end the stream instead
+			code, lines = create(t, handler, tt.body)
+			if (code != tt.want && code != http.StatusOK) || len(lines) == 0 || lines[len(lines)-1] == "success" {
+				t.Errorf("Expected the stream to end with an error, got %d: %v", code, lines)
+			}
+		})
+	}
+}
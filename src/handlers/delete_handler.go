@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// DeleteHandler handles requests to /api/delete. Only models created through
+// the proxy can be deleted; upstream models are not found, and aliases of the
+// configuration file have to be removed there.
+func DeleteHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var deleteReq models.OllamaDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&deleteReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	name := deleteReq.Model
+	if name == "" {
+		name = deleteReq.Name
+	}
+	if name == "" {
+		writeOllamaError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	if err := registry.DeleteAlias(name); err != nil {
+		writeAliasError(w, "deleting", name, err)
+		return
+	}
+	log.Printf("Deleted model %s", name)
+	w.WriteHeader(http.StatusOK)
+}
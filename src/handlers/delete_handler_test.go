@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+)
+
+func TestDeleteHandler(t *testing.T) {
+	registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"), reviewerAlias())
+	if _, err := registry.CreateAlias(config.AliasConfig{Name: "helper", Model: "gpt-4o"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteModel := func(body string) int {
+		req, _ := http.NewRequest("DELETE", "/api/delete", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		DeleteHandler(rr, req, registry)
+		return rr.Code
+	}
+
+	if code := deleteModel(`{"model":"helper"}`); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if _, ok := registry.Alias("helper"); ok {
+		t.Error("Expected the alias to be gone")
+	}
+
+	tests := map[string]int{
+		`{"model":"helper"}`:   http.StatusNotFound,   // Already deleted
+		`{"model":"gpt-4o"}`:   http.StatusNotFound,   // Upstream models can't be deleted
+		`{"model":"reviewer"}`: http.StatusBadRequest, // Defined in the configuration file
+		`{}`:                   http.StatusBadRequest,
+	}
+	for body, want := range tests {
+		if code := deleteModel(body); code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, code)
+		}
+	}
+}
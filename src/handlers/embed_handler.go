@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// EmbedHandler handles requests to /api/embed.
+// Inputs are sent to /v1/embeddings in a single batch. `truncate` is accepted
+// for compatibility only: OpenAI-compatible APIs reject inputs that exceed the
+// model context instead of truncating them.
+func EmbedHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ollamaReq models.OllamaEmbedRequest
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request: Could not read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(bodyBytes, &ollamaReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	targets, ok := routeModel(w, registry, ollamaReq.Model, r.Header.Get("Authorization"))
+	if !ok {
+		return
+	}
+	keepAlive, err := parseKeepAlive(ollamaReq.KeepAlive)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid keep_alive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	inputs, err := parseEmbedInput(ollamaReq.Input)
+	if err != nil {
+		http.Error(w, "Bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startTime := time.Now()
+	ollamaResp := models.OllamaEmbedResponse{
+		Model:      ollamaReq.Model,
+		Embeddings: [][]float64{},
+	}
+	if len(inputs) > 0 {
+		inFlight := registry.BeginRequest(ollamaReq.Model, keepAlive)
+		defer inFlight.End()
+		openAIResp, ok := fetchEmbeddings(r.Context(), w, targets, r.Header.Get("Authorization"), models.OpenAIEmbeddingRequest{
+			Model:          ollamaReq.Model,
+			Input:          inputs,
+			Dimensions:     ollamaReq.Dimensions,
+			EncodingFormat: "float",
+		})
+		if !ok {
+			return
+		}
+		inFlight.Accept()
+		for _, embedding := range openAIResp.Data {
+			ollamaResp.Embeddings = append(ollamaResp.Embeddings, embedding.Embedding)
+		}
+		ollamaResp.PromptEvalCount = openAIResp.Usage.PromptTokens
+	}
+	ollamaResp.TotalDuration = time.Since(startTime).Nanoseconds()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ollamaResp); err != nil {
+		log.Printf("Error encoding Ollama embed response: %v", err)
+	}
+}
+
+// EmbeddingsHandler handles requests to Ollama's legacy /api/embeddings.
+func EmbeddingsHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ollamaReq models.OllamaEmbeddingsRequest
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request: Could not read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(bodyBytes, &ollamaReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	targets, ok := routeModel(w, registry, ollamaReq.Model, r.Header.Get("Authorization"))
+	if !ok {
+		return
+	}
+	keepAlive, err := parseKeepAlive(ollamaReq.KeepAlive)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid keep_alive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ollamaResp := models.OllamaEmbeddingsResponse{Embedding: []float64{}}
+	if ollamaReq.Prompt != "" { // Ollama answers an empty prompt with an empty embedding
+		inFlight := registry.BeginRequest(ollamaReq.Model, keepAlive)
+		defer inFlight.End()
+		openAIResp, ok := fetchEmbeddings(r.Context(), w, targets, r.Header.Get("Authorization"), models.OpenAIEmbeddingRequest{
+			Model:          ollamaReq.Model,
+			Input:          []string{ollamaReq.Prompt},
+			EncodingFormat: "float",
+		})
+		if !ok {
+			return
+		}
+		inFlight.Accept()
+		if len(openAIResp.Data) > 0 {
+			ollamaResp.Embedding = openAIResp.Data[0].Embedding
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ollamaResp); err != nil {
+		log.Printf("Error encoding Ollama embeddings response: %v", err)
+	}
+}
+
+// fetchEmbeddings calls /v1/embeddings on the first target that answers and
+// returns the embeddings ordered by input index. On failure it answers the
+// client itself and reports false.
+func fetchEmbeddings(ctx context.Context, w http.ResponseWriter, targets []backends.Target, clientAuth string, openAIReq models.OpenAIEmbeddingRequest) (*models.OpenAIEmbeddingResponse, bool) {
+	resp, err := postWithFailover(ctx, targets, clientAuth, "/v1/embeddings", false, func(target backends.Target) interface{} {
+		upstreamReq := openAIReq
+		upstreamReq.Model = target.Model
+		return upstreamReq
+	})
+	if err != nil {
+		if clientDisconnected(ctx, openAIReq.Model) {
+			return nil, false
+		}
+		log.Printf("Error making request to OpenAI: %v", err)
+		http.Error(w, "Failed to communicate with OpenAI API", http.StatusInternalServerError)
+		return nil, false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		forwardOpenAIError(w, resp)
+		return nil, false
+	}
+
+	var openAIResp models.OpenAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
+		log.Printf("Error decoding OpenAI embeddings response: %v", err)
+		http.Error(w, "Failed to decode OpenAI response", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(openAIResp.Data) != len(openAIReq.Input) {
+		log.Printf("OpenAI returned %d embeddings for %d inputs", len(openAIResp.Data), len(openAIReq.Input))
+		http.Error(w, "Unexpected number of embeddings from OpenAI", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	sort.Slice(openAIResp.Data, func(i, j int) bool {
+		return openAIResp.Data[i].Index < openAIResp.Data[j].Index
+	})
+	return &openAIResp, true
+}
+
+// parseEmbedInput accepts the `input` field as a single string or an array of strings.
+func parseEmbedInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, nil
+		}
+		return []string{single}, nil
+	}
+
+	var batch []string
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return nil, errors.New("input must be a string or an array of strings")
+	}
+	return batch, nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/models"
+)
+
+func newEmbeddingsMock(t *testing.T, received *models.OpenAIEmbeddingRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("Mock OpenAI: unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer testtoken" {
+			t.Errorf("Mock OpenAI: unexpected Authorization header '%s'", r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(received)
+
+		// Return the embeddings in reverse order to check they are sorted by index
+		resp := models.OpenAIEmbeddingResponse{Object: "list", Model: received.Model, Usage: models.OpenAIEmbeddingUsage{PromptTokens: 7}}
+		for i := len(received.Input) - 1; i >= 0; i-- {
+			resp.Data = append(resp.Data, models.OpenAIEmbedding{Object: "embedding", Index: i, Embedding: []float64{float64(i), 0.5}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestEmbedHandler_Batch(t *testing.T) {
+	var openAIReq models.OpenAIEmbeddingRequest
+	mockOpenAIServer := newEmbeddingsMock(t, &openAIReq)
+	defer mockOpenAIServer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"text-embedding-3-small","input":["a","b"],"dimensions":2}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	EmbedHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if !reflect.DeepEqual(openAIReq.Input, []string{"a", "b"}) || openAIReq.Dimensions != 2 {
+		t.Errorf("Unexpected upstream request: %+v", openAIReq)
+	}
+
+	var resp models.OllamaEmbedResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	expected := [][]float64{{0, 0.5}, {1, 0.5}}
+	if !reflect.DeepEqual(resp.Embeddings, expected) {
+		t.Errorf("Unexpected embeddings: got %v want %v", resp.Embeddings, expected)
+	}
+	if resp.Model != "text-embedding-3-small" || resp.PromptEvalCount != 7 {
+		t.Errorf("Unexpected response metadata: %+v", resp)
+	}
+}
+
+func TestEmbedHandler_SingleString(t *testing.T) {
+	var openAIReq models.OpenAIEmbeddingRequest
+	mockOpenAIServer := newEmbeddingsMock(t, &openAIReq)
+	defer mockOpenAIServer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"nomic","input":"hello","truncate":false}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	EmbedHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	var resp models.OllamaEmbedResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if !reflect.DeepEqual(openAIReq.Input, []string{"hello"}) || len(resp.Embeddings) != 1 {
+		t.Errorf("Unexpected request %+v or response %+v", openAIReq, resp)
+	}
+}
+
+func TestEmbedHandler_InvalidInput(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"nomic","input":42}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	EmbedHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEmbedHandler_OpenAIError(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":{"message":"maximum context length exceeded","code":"context_length_exceeded"}}`)
+	}))
+	defer mockOpenAIServer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/embed", strings.NewReader(`{"model":"nomic","input":"long"}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	EmbedHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "context_length_exceeded") {
+		t.Errorf("Expected upstream error to be forwarded, got: %s", rr.Body.String())
+	}
+}
+
+func TestEmbeddingsHandler_Legacy(t *testing.T) {
+	var openAIReq models.OpenAIEmbeddingRequest
+	mockOpenAIServer := newEmbeddingsMock(t, &openAIReq)
+	defer mockOpenAIServer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/embeddings", strings.NewReader(`{"model":"nomic","prompt":"hello"}`))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	EmbeddingsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp models.OllamaEmbeddingsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if !reflect.DeepEqual(resp.Embedding, []float64{0, 0.5}) {
+		t.Errorf("Unexpected embedding: %v", resp.Embedding)
+	}
+}
+
+func TestEmbeddingsHandler_MissingAuthHeader(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/embeddings", strings.NewReader(`{"model":"nomic","prompt":"hello"}`))
+	rr := httptest.NewRecorder()
+	EmbeddingsHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ollama-openai-proxy/src/jsonschema"
+	"ollama-openai-proxy/src/models"
+)
+
+// toResponseFormat translates Ollama's `format` into an OpenAI response_format.
+// Ollama accepts the string "json" for any JSON object or a JSON schema.
+func toResponseFormat(format json.RawMessage) (*models.OpenAIResponseFormat, error) {
+	if isEmptyFormat(format) {
+		return nil, nil
+	}
+
+	var name string
+	if json.Unmarshal(format, &name) == nil {
+		if name != "json" {
+			return nil, fmt.Errorf("unsupported format %q", name)
+		}
+		return &models.OpenAIResponseFormat{Type: "json_object"}, nil
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(format, &schema); err != nil {
+		return nil, errors.New(`format must be "json" or a JSON schema object`)
+	}
+	return &models.OpenAIResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &models.OpenAIJSONSchema{
+			Name:   "response",
+			Schema: format,
+		},
+	}, nil
+}
+
+// checkFormat verifies that content satisfies the requested format.
+func checkFormat(format json.RawMessage, content string) error {
+	var name string
+	if json.Unmarshal(format, &name) == nil { // "json"
+		if !json.Valid([]byte(content)) {
+			return errors.New("response is not valid JSON")
+		}
+		return nil
+	}
+	return jsonschema.Validate(format, []byte(content))
+}
+
+// formatToValidate returns the format responses must be checked against, or
+// nil when there is nothing to validate.
+func formatToValidate(format json.RawMessage, validate bool) json.RawMessage {
+	if !validate || isEmptyFormat(format) {
+		return nil
+	}
+	return format
+}
+
+func isEmptyFormat(format json.RawMessage) bool {
+	return len(format) == 0 || string(format) == "null" || string(format) == `""`
+}
+
+// formatMismatchMessage is the error reported when a response doesn't match the requested format.
+func formatMismatchMessage(err error) string {
+	return "response does not match the requested format: " + err.Error()
+}
+
+// writeOllamaError answers the client with an Ollama style {"error": "..."} body.
+func writeOllamaError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(models.OllamaErrorResponse{Error: message}); err != nil {
+		log.Printf("Error encoding Ollama error response: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToResponseFormat(t *testing.T) {
+	format, err := toResponseFormat(json.RawMessage(`"json"`))
+	if err != nil || format == nil || format.Type != "json_object" || format.JSONSchema != nil {
+		t.Errorf(`Expected json_object for "json", got %+v (err: %v)`, format, err)
+	}
+
+	schema := `{"type":"object","properties":{"age":{"type":"integer"}}}`
+	format, err = toResponseFormat(json.RawMessage(schema))
+	if err != nil || format == nil || format.Type != "json_schema" {
+		t.Fatalf("Expected json_schema, got %+v (err: %v)", format, err)
+	}
+	if format.JSONSchema.Name == "" || string(format.JSONSchema.Schema) != schema {
+		t.Errorf("Unexpected json_schema: %+v", format.JSONSchema)
+	}
+
+	for _, empty := range []string{``, `null`, `""`} {
+		if format, err := toResponseFormat(json.RawMessage(empty)); format != nil || err != nil {
+			t.Errorf("Expected no response format for %q, got %+v (err: %v)", empty, format, err)
+		}
+	}
+
+	for _, invalid := range []string{`"yaml"`, `42`, `["json"]`} {
+		if _, err := toResponseFormat(json.RawMessage(invalid)); err == nil {
+			t.Errorf("Expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	if err := checkFormat(json.RawMessage(`"json"`), `{"a":1}`); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := checkFormat(json.RawMessage(`"json"`), `Sure! {"a":1}`); err == nil {
+		t.Error("Expected invalid JSON to be reported")
+	}
+	schema := json.RawMessage(`{"type":"object","required":["a"]}`)
+	if err := checkFormat(schema, `{"b":1}`); err == nil {
+		t.Error("Expected schema mismatch to be reported")
+	}
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/models"
+)
+
+// geminiAPIPath is the version prefix of the Gemini API endpoints.
+const geminiAPIPath = "/v1beta"
+
+// geminiThinkingBudgets maps reasoning_effort to a thinking token budget.
+var geminiThinkingBudgets = map[string]int{"low": 1024, "medium": 4096, "high": 16384}
+
+// postToGemini sends an OpenAI chat completion request to a Gemini backend's
+// generateContent, or streamGenerateContent when streaming, and converts a
+// successful answer back into an OpenAI chat completion or SSE stream. Error
+// responses are passed on unchanged; like OpenAI's they carry an "error"
+// object. Other endpoints are answered with 501 Not Implemented, requests that
+// can't be converted with 400.
+func postToGemini(ctx context.Context, backend *backends.Backend, path, authToken string, payload interface{}, stream bool) (*http.Response, error) {
+	chatReq, ok := payload.(models.OpenAIChatRequest)
+	if path != "/v1/chat/completions" || !ok {
+		return unsupportedPathResponse(backend, path), nil
+	}
+	geminiReq, err := toGeminiRequest(chatReq)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid_request_error", err.Error()), nil
+	}
+	reqBodyBytes, err := json.Marshal(geminiReq)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling Gemini request: %w", err)
+	}
+
+	endpoint := backend.BaseURL + geminiAPIPath + "/models/" + url.PathEscape(chatReq.Model)
+	if stream {
+		endpoint += ":streamGenerateContent?alt=sse"
+	} else {
+		endpoint += ":generateContent"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(reqBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request to Gemini: %w", err)
+	}
+	setAuthHeaders(httpReq, backend, authToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := backend.Client.Do(httpReq, stream)
+	if err != nil {
+		return nil, fmt.Errorf("making request to Gemini: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	if stream {
+		pr, pw := io.Pipe()
+		go translateGeminiStream(resp.Body, pw, chatReq.Model)
+		resp.Body = &translatedBody{PipeReader: pr, upstream: resp.Body}
+		return resp, nil
+	}
+
+	var geminiResp models.GeminiResponse
+	err = json.NewDecoder(resp.Body).Decode(&geminiResp)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("decoding Gemini response: %w", err)
+	}
+	if err := replaceBody(resp, fromGeminiResponse(geminiResp, chatReq.Model)); err != nil {
+		return nil, fmt.Errorf("marshalling converted Gemini response: %w", err)
+	}
+	return resp, nil
+}
+
+// decodeGeminiModels reads Gemini's model list as OpenAI models, leaving out
+// models that can't generate content, such as embedding models.
+func decodeGeminiModels(body io.Reader) ([]models.OpenAIModel, error) {
+	var geminiResp models.GeminiModelsResponse
+	if err := json.NewDecoder(body).Decode(&geminiResp); err != nil {
+		return nil, err
+	}
+	var openAIModels []models.OpenAIModel
+	for _, model := range geminiResp.Models {
+		for _, method := range model.SupportedGenerationMethods {
+			if method == "generateContent" {
+				id := strings.TrimPrefix(model.Name, "models/")
+				openAIModels = append(openAIModels, models.OpenAIModel{ID: id, Object: "model", OwnedBy: "google"})
+				break
+			}
+		}
+	}
+	return openAIModels, nil
+}
+
+// toGeminiRequest converts an OpenAI chat completion request. System messages
+// become the system instruction, assistant messages "model" turns and tool
+// results function responses in "user" turns. Consecutive turns of the same
+// role are merged, so that the results of parallel calls share one turn.
+func toGeminiRequest(req models.OpenAIChatRequest) (models.GeminiRequest, error) {
+	var out models.GeminiRequest
+
+	genConfig := models.GeminiGenerationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		TopK:             req.TopK,
+		MaxOutputTokens:  req.MaxTokens,
+		StopSequences:    req.Stop,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if genConfig.MaxOutputTokens == nil {
+		genConfig.MaxOutputTokens = req.MaxCompletionTokens
+	}
+	if format := req.ResponseFormat; format != nil {
+		genConfig.ResponseMimeType = "application/json"
+		if format.JSONSchema != nil {
+			genConfig.ResponseJSONSchema = format.JSONSchema.Schema
+		}
+	}
+	if budget, ok := geminiThinkingBudgets[req.ReasoningEffort]; ok {
+		genConfig.ThinkingConfig = &models.GeminiThinkingConfig{ThinkingBudget: budget, IncludeThoughts: true}
+	}
+	if !reflect.ValueOf(genConfig).IsZero() {
+		out.GenerationConfig = &genConfig
+	}
+
+	if len(req.Tools) > 0 {
+		declarations := make([]models.GeminiFunctionDeclaration, len(req.Tools))
+		for i, tool := range req.Tools {
+			declarations[i] = models.GeminiFunctionDeclaration{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+			}
+			if schema := tool.Function.Parameters; len(schema) > 0 && string(schema) != "null" {
+				declarations[i].Parameters = schema
+			}
+		}
+		out.Tools = []models.GeminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	var system []models.GeminiPart
+	toolNames := make(map[string]string) // Tool call ID -> function name
+	for i, msg := range req.Messages {
+		var parts []models.GeminiPart
+		role := "user"
+		switch msg.Role {
+		case "system":
+			if text := messageText(msg); text != "" {
+				system = append(system, models.GeminiPart{Text: text})
+			}
+			continue
+		case "tool":
+			name, ok := toolNames[msg.ToolCallID]
+			if !ok {
+				return out, fmt.Errorf("message %d: tool result does not answer a preceding tool call", i)
+			}
+			parts = append(parts, models.GeminiPart{FunctionResponse: &models.GeminiFunctionResponse{
+				Name:     name,
+				Response: geminiFunctionResponse(messageText(msg)),
+			}})
+		case "assistant":
+			role = "model"
+			if msg.Content != "" {
+				parts = append(parts, models.GeminiPart{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				toolNames[call.ID] = call.Function.Name
+				args := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(args) {
+					log.Printf("Invalid arguments of tool call %s, sending {} to Gemini", call.ID)
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, models.GeminiPart{FunctionCall: &models.GeminiFunctionCall{Name: call.Function.Name, Args: args}})
+			}
+		default:
+			var err error
+			if parts, err = geminiUserParts(msg); err != nil {
+				return out, fmt.Errorf("message %d: %w", i, err)
+			}
+		}
+		if len(parts) == 0 {
+			continue // Gemini rejects turns without parts
+		}
+
+		if last := len(out.Contents) - 1; last >= 0 && out.Contents[last].Role == role {
+			out.Contents[last].Parts = append(out.Contents[last].Parts, parts...)
+		} else {
+			out.Contents = append(out.Contents, models.GeminiContent{Role: role, Parts: parts})
+		}
+	}
+	if len(system) > 0 {
+		out.SystemInstruction = &models.GeminiContent{Parts: system}
+	}
+	return out, nil
+}
+
+// geminiFunctionResponse wraps a tool result in the object Gemini expects.
+// Results that are JSON objects already are sent as they are.
+func geminiFunctionResponse(result string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if json.Unmarshal([]byte(result), &object) == nil && object != nil {
+		return json.RawMessage(result)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"output": result})
+	return wrapped
+}
+
+// geminiUserParts converts the text and images of a user message. Images
+// given as data URIs are sent inline, other URLs by reference.
+func geminiUserParts(msg models.OpenAIChatMessage) ([]models.GeminiPart, error) {
+	if len(msg.ContentParts) == 0 {
+		if msg.Content == "" {
+			return nil, nil
+		}
+		return []models.GeminiPart{{Text: msg.Content}}, nil
+	}
+
+	var parts []models.GeminiPart
+	for _, part := range msg.ContentParts {
+		switch {
+		case part.Type == "text" && part.Text != "":
+			parts = append(parts, models.GeminiPart{Text: part.Text})
+		case part.Type == "image_url" && part.ImageURL != nil:
+			if !strings.HasPrefix(part.ImageURL.URL, "data:") {
+				parts = append(parts, models.GeminiPart{FileData: &models.GeminiFileData{FileURI: part.ImageURL.URL}})
+				continue
+			}
+			mimeType, data, err := parseDataURI(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, models.GeminiPart{InlineData: &models.GeminiBlob{MimeType: mimeType, Data: data}})
+		}
+	}
+	return parts, nil
+}
+
+// fromGeminiResponse converts a complete Gemini response into an OpenAI chat
+// completion. A blocked prompt yields an empty message.
+func fromGeminiResponse(resp models.GeminiResponse, model string) models.OpenAIChatResponse {
+	message := models.OpenAIChatMessage{Role: "assistant"}
+	finishReason := geminiBlockedFinishReason(resp, model)
+	if len(resp.Candidates) > 0 {
+		candidate := resp.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				message.ToolCalls = append(message.ToolCalls, geminiToolCall(part.FunctionCall, len(message.ToolCalls)))
+			case part.Thought:
+				message.ReasoningContent += part.Text
+			default:
+				message.Content += part.Text
+			}
+		}
+		finishReason = geminiFinishReason(candidate.FinishReason, len(message.ToolCalls) > 0)
+	}
+	if resp.ModelVersion != "" {
+		model = resp.ModelVersion
+	}
+	return models.OpenAIChatResponse{
+		ID:      resp.ResponseID,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []models.OpenAIChatChoice{{Message: message, FinishReason: finishReason}},
+		Usage:   geminiUsage(resp.UsageMetadata),
+	}
+}
+
+// geminiBlockedFinishReason returns content_filter when the prompt was
+// blocked, and stop otherwise.
+func geminiBlockedFinishReason(resp models.GeminiResponse, model string) string {
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		log.Printf("Gemini blocked the prompt for %s: %s", model, resp.PromptFeedback.BlockReason)
+		return "content_filter"
+	}
+	return "stop"
+}
+
+// geminiToolCall converts a function call. Gemini only sometimes assigns IDs;
+// missing ones are made up from the call's position.
+func geminiToolCall(call *models.GeminiFunctionCall, index int) models.OpenAIToolCall {
+	id := call.ID
+	if id == "" {
+		id = fmt.Sprintf("call_%d", index)
+	}
+	return models.OpenAIToolCall{
+		ID:       id,
+		Type:     "function",
+		Function: models.OpenAIToolCallFunction{Name: call.Name, Arguments: toolInputString(call.Args)},
+	}
+}
+
+// geminiFinishReason maps a finishReason to an OpenAI finish_reason. Gemini
+// finishes function calls with STOP.
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	}
+	if toolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// geminiUsage converts Gemini's token counts. Thinking tokens are counted as
+// completion tokens, as OpenAI does.
+func geminiUsage(usage *models.GeminiUsageMetadata) *models.OpenAIUsage {
+	if usage == nil {
+		return nil
+	}
+	completionTokens := usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+	return &models.OpenAIUsage{
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: completionTokens,
+		TotalTokens:      usage.PromptTokenCount + completionTokens,
+	}
+}
+
+// translateGeminiStream reads Gemini's SSE events from body, each a partial
+// response, and writes the equivalent OpenAI chat completion chunks to w.
+// Gemini sends no end marker, so the usage chunk and [DONE] follow when body
+// ends after a finish reason. An error, a read error or a stream ending
+// without finish reason closes w with an error, which the reader sees.
+func translateGeminiStream(body io.Reader, w *io.PipeWriter, model string) {
+	var (
+		chunks    = &chunkWriter{w: w, model: model}
+		usage     *models.GeminiUsageMetadata
+		started   bool
+		finished  bool
+		toolCalls int
+	)
+	write := chunks.write
+
+	events := newSSEReader(body)
+	for {
+		event, err := events.Next()
+		if err == io.EOF && finished {
+			chunks.finish(geminiUsage(usage))
+			return
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // No finish reason
+			}
+			w.CloseWithError(err)
+			return
+		}
+
+		var geminiResp models.GeminiResponse
+		if err := json.Unmarshal([]byte(event.Data), &geminiResp); err != nil {
+			log.Printf("Error unmarshalling Gemini stream event '%s': %v", event.Data, err)
+			continue // Skip malformed event
+		}
+		if geminiResp.Error != nil {
+			w.CloseWithError(fmt.Errorf("Gemini stream error: %s: %s", geminiResp.Error.Status, geminiResp.Error.Message))
+			return
+		}
+		if geminiResp.UsageMetadata != nil {
+			usage = geminiResp.UsageMetadata
+		}
+		if !started {
+			started = true
+			chunks.id = geminiResp.ResponseID
+			if geminiResp.ModelVersion != "" {
+				chunks.model = geminiResp.ModelVersion
+			}
+			if err = write(models.OpenAIChatMessage{Role: "assistant"}, "", nil); err != nil {
+				w.CloseWithError(err) // The reader is gone
+				return
+			}
+		}
+
+		if len(geminiResp.Candidates) == 0 {
+			if reason := geminiBlockedFinishReason(geminiResp, model); reason != "stop" {
+				finished = true
+				err = write(models.OpenAIChatMessage{}, reason, nil)
+			}
+		} else {
+			candidate := geminiResp.Candidates[0]
+			for _, part := range candidate.Content.Parts {
+				switch {
+				case part.FunctionCall != nil:
+					index := toolCalls
+					toolCalls++
+					call := geminiToolCall(part.FunctionCall, index)
+					call.Index = &index
+					err = write(models.OpenAIChatMessage{ToolCalls: []models.OpenAIToolCall{call}}, "", nil)
+				case part.Thought:
+					err = write(models.OpenAIChatMessage{ReasoningContent: part.Text}, "", nil)
+				case part.Text != "":
+					err = write(models.OpenAIChatMessage{Content: part.Text}, "", nil)
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err == nil && candidate.FinishReason != "" {
+				finished = true
+				err = write(models.OpenAIChatMessage{}, geminiFinishReason(candidate.FinishReason, toolCalls > 0), nil)
+			}
+		}
+
+		if err != nil {
+			w.CloseWithError(err) // The reader is gone
+			return
+		}
+	}
+}
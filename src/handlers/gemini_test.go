@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// geminiBackend serves every model from the Gemini API at url.
+func geminiBackend(url string) config.BackendConfig {
+	return config.BackendConfig{
+		Name:    "gemini",
+		BaseURL: url,
+		Type:    config.BackendTypeGemini,
+		APIKey:  "gm-key",
+	}
+}
+
+func TestToGeminiRequest(t *testing.T) {
+	temperature := 0.5
+	req := models.OpenAIChatRequest{
+		Model: "gemini-2.5-flash",
+		Messages: []models.OpenAIChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "What's in the picture?", ContentParts: []models.OpenAIContentPart{
+				{Type: "text", Text: "What's in the picture?"},
+				{Type: "image_url", ImageURL: &models.OpenAIImageURL{URL: "data:image/png;base64,iVBORw0KGgo="}},
+			}},
+			{Role: "assistant", ToolCalls: []models.OpenAIToolCall{
+				{ID: "call_0", Type: "function", Function: models.OpenAIToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_1", Type: "function", Function: models.OpenAIToolCallFunction{Name: "time", Arguments: `{}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_0", Content: "Sunny"},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"time":"12:00"}`},
+		},
+		Tools:          []models.OpenAITool{{Type: "function", Function: models.OpenAIToolFunction{Name: "weather", Parameters: json.RawMessage(`{"type":"object"}`)}}},
+		ResponseFormat: &models.OpenAIResponseFormat{Type: "json_object"},
+		OpenAISamplingParams: models.OpenAISamplingParams{
+			Temperature: &temperature,
+			TopK:        intPtr(40),
+			MaxTokens:   intPtr(200),
+			Stop:        []string{"END"},
+		},
+	}
+
+	got, err := toGeminiRequest(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := models.GeminiRequest{
+		Contents: []models.GeminiContent{
+			{Role: "user", Parts: []models.GeminiPart{
+				{Text: "What's in the picture?"},
+				{InlineData: &models.GeminiBlob{MimeType: "image/png", Data: "iVBORw0KGgo="}},
+			}},
+			{Role: "model", Parts: []models.GeminiPart{
+				{FunctionCall: &models.GeminiFunctionCall{Name: "weather", Args: json.RawMessage(`{"city":"Paris"}`)}},
+				{FunctionCall: &models.GeminiFunctionCall{Name: "time", Args: json.RawMessage(`{}`)}},
+			}},
+			{Role: "user", Parts: []models.GeminiPart{ // Results of parallel calls share a turn
+				{FunctionResponse: &models.GeminiFunctionResponse{Name: "weather", Response: json.RawMessage(`{"output":"Sunny"}`)}},
+				{FunctionResponse: &models.GeminiFunctionResponse{Name: "time", Response: json.RawMessage(`{"time":"12:00"}`)}},
+			}},
+		},
+		SystemInstruction: &models.GeminiContent{Parts: []models.GeminiPart{{Text: "Be brief."}}},
+		Tools: []models.GeminiTool{{FunctionDeclarations: []models.GeminiFunctionDeclaration{
+			{Name: "weather", Parameters: json.RawMessage(`{"type":"object"}`)},
+		}}},
+		GenerationConfig: &models.GeminiGenerationConfig{
+			Temperature:      &temperature,
+			TopK:             intPtr(40),
+			MaxOutputTokens:  intPtr(200),
+			StopSequences:    []string{"END"},
+			ResponseMimeType: "application/json",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		t.Errorf("Got  %s\nwant %s", gotJSON, wantJSON)
+	}
+
+	// A tool result without a matching call can't be named
+	req.Messages = []models.OpenAIChatMessage{{Role: "tool", ToolCallID: "call_9", Content: "Sunny"}}
+	if _, err := toGeminiRequest(req); err == nil {
+		t.Error("Expected an error for an unmatched tool result")
+	}
+}
+
+func TestChatHandler_GeminiNonStreaming(t *testing.T) {
+	var received models.GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:generateContent" || r.Header.Get("x-goog-api-key") != "gm-key" {
+			t.Errorf("Unexpected request %s with key %q", r.URL.Path, r.Header.Get("x-goog-api-key"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[
+				{"text":"Paris, obviously.","thought":true},{"text":"Let me check."},
+				{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],
+			"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":20,"thoughtsTokenCount":10,"totalTokenCount":42},
+			"modelVersion":"gemini-2.5-flash","responseId":"resp_1"}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"gemini-2.5-flash","stream":false,"think":true,"options":{"top_k":20},"messages":[{"role":"user","content":"Weather in Paris?"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	cfg := testConfig("")
+	cfg.ReasoningModels = []string{"gemini-2.5-*"}
+	ChatHandler(rr, req, cfg, testRegistry(t, geminiBackend(server.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", rr.Code, rr.Body.String())
+	}
+	if genConfig := received.GenerationConfig; genConfig == nil || genConfig.ThinkingConfig == nil || genConfig.TopK == nil || *genConfig.TopK != 20 {
+		t.Errorf("Unexpected generation config: %+v", received.GenerationConfig)
+	}
+	var resp models.OllamaChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Message.Content != "Let me check." || resp.Message.Thinking != "Paris, obviously." {
+		t.Errorf("Unexpected message: %+v", resp.Message)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].Function.Name != "weather" || string(resp.Message.ToolCalls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool calls: %+v", resp.Message.ToolCalls)
+	}
+	if resp.OllamaMetrics == nil || resp.PromptEvalCount != 12 || resp.EvalCount != 30 {
+		t.Errorf("Unexpected metrics: %+v", resp.OllamaMetrics)
+	}
+}
+
+func TestChatHandler_GeminiBlockedPrompt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":8,"totalTokenCount":8}}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"gemini-2.5-flash","stream":false,"messages":[{"role":"user","content":"Something bad"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(""), testRegistry(t, geminiBackend(server.URL)))
+
+	var resp models.OllamaChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || !resp.Done || resp.Message.Content != "" || resp.DoneReason != "stop" {
+		t.Errorf("Unexpected response %d: %+v", rr.Code, resp)
+	}
+}
+
+func TestChatHandler_GeminiStreaming(t *testing.T) {
+	events := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]}}],"modelVersion":"gemini-2.5-flash","responseId":"resp_1"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":" there"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],
+			"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":25,"totalTokenCount":37}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			io.WriteString(w, "data: "+strings.ReplaceAll(event, "\n", "")+"\r\n\r\n")
+		}
+	}))
+	defer server.Close()
+
+	body := `{"model":"gemini-2.5-flash","stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	req, _ := http.NewRequest("POST", "/api/chat", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	ChatHandler(rr, req, testConfig(""), testRegistry(t, geminiBackend(server.URL)))
+
+	var chunks []models.OllamaStreamChunk
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var chunk models.OllamaStreamChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("Invalid chunk %q: %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	var content strings.Builder
+	var calls []models.OllamaToolCall
+	for _, chunk := range chunks {
+		content.WriteString(chunk.Message.Content)
+		calls = append(calls, chunk.Message.ToolCalls...)
+	}
+	if content.String() != "Hello there" {
+		t.Errorf("Got content %q", content.String())
+	}
+	if len(calls) != 1 || calls[0].Function.Name != "weather" || string(calls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("Unexpected tool calls: %+v", calls)
+	}
+	last := chunks[len(chunks)-1]
+	if !last.Done || last.OllamaMetrics == nil || last.PromptEvalCount != 12 || last.EvalCount != 25 {
+		t.Errorf("Unexpected final chunk: %+v", last)
+	}
+}
+
+func TestGetModelsHandler_Gemini(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models" || r.Header.Get("x-goog-api-key") != "gm-key" {
+			t.Errorf("Unexpected request %s with key %q", r.URL.Path, r.Header.Get("x-goog-api-key"))
+		}
+		io.WriteString(w, `{"models":[
+			{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash","supportedGenerationMethods":["generateContent","countTokens"]},
+			{"name":"models/text-embedding-004","displayName":"Text Embedding 004","supportedGenerationMethods":["embedContent"]}]}`)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, testRegistry(t, geminiBackend(server.URL)))
+
+	var resp models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Models) != 1 || resp.Models[0].Name != "gemini-2.5-flash" {
+		t.Errorf("Unexpected models: %+v", resp.Models)
+	}
+}
@@ -0,0 +1,470 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// responsePlaceholder marks where the model's answer starts in a rendered
+// template. Everything after it belongs to the response and is cut off.
+const responsePlaceholder = "\x00response\x00"
+
+// GenerateHandler handles requests to /api/generate.
+// Regular prompts are sent to /v1/chat/completions. Raw prompts, fill-in-the-middle
+// requests (suffix) and custom templates need an untouched prompt and use the
+// legacy /v1/completions endpoint instead. The request goes to the backend
+// serving the requested model.
+func GenerateHandler(w http.ResponseWriter, r *http.Request, cfg config.AppConfig, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	timer := newRequestTimer()
+
+	var ollamaReq models.OllamaGenerateRequest
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Bad request: Could not read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(bodyBytes, &ollamaReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	stream := ollamaReq.Stream == nil || *ollamaReq.Stream
+
+	if alias, ok := registry.Alias(ollamaReq.Model); ok {
+		applyGenerateAlias(&ollamaReq, alias)
+	}
+
+	targets, ok := routeModel(w, registry, ollamaReq.Model, r.Header.Get("Authorization"))
+	if !ok {
+		return
+	}
+	model := targets[0].Model // The first choice, for model-specific handling
+	keepAlive, err := parseKeepAlive(ollamaReq.KeepAlive)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid keep_alive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Ollama clients send an empty prompt to preload a model, or with a
+	// keep_alive of 0 to unload it. There is nothing to load behind an
+	// OpenAI-compatible API, so answer right away. As nothing reaches the
+	// upstream, only unloading changes what /api/ps lists.
+	if ollamaReq.Prompt == "" && ollamaReq.Suffix == "" {
+		doneReason := "load"
+		if keepAlive != nil && *keepAlive == 0 {
+			doneReason = "unload"
+			registry.Unload(ollamaReq.Model)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.OllamaGenerateResponse{
+			Model:     ollamaReq.Model,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Done:      true,
+
+			OllamaMetrics: &models.OllamaMetrics{DoneReason: doneReason},
+		})
+		return
+	}
+
+	completions := useCompletionsAPI(ollamaReq)
+	if len(ollamaReq.Images) > 0 {
+		switch {
+		case matchesModel(model, cfg.TextOnlyModels):
+			if cfg.TextOnlyImagePolicy != "strip" {
+				http.Error(w, "Bad request: "+errImagesNotSupported.Error()+": "+ollamaReq.Model, http.StatusBadRequest)
+				return
+			}
+			log.Printf("Stripping %d image(s) sent to text-only model %s", len(ollamaReq.Images), ollamaReq.Model)
+			ollamaReq.Images = nil
+		case completions:
+			// Plain text prompts have no place for images
+			log.Printf("Dropping %d image(s) from completion request for %s", len(ollamaReq.Images), ollamaReq.Model)
+			ollamaReq.Images = nil
+		}
+	}
+	maxTokensField := cfg.MaxTokensField
+	if completions {
+		maxTokensField = "max_tokens" // The only field /v1/completions knows
+	}
+	samplingParams, err := translateOllamaOptions(ollamaReq.Options, maxTokensField)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responseFormat, err := toResponseFormat(ollamaReq.Format)
+	if err != nil {
+		http.Error(w, "Bad request: Invalid format: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	validateFormat := formatToValidate(ollamaReq.Format, cfg.ValidateStructuredOutput)
+
+	var (
+		path     string
+		payload  func(backends.Target) interface{}
+		messages []models.OpenAIChatMessage
+	)
+	if completions {
+		prompt, err := buildCompletionPrompt(ollamaReq)
+		if err != nil {
+			http.Error(w, "Bad request: Could not render template: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if responseFormat != nil {
+			// /v1/completions has no response_format, the prompt has to ask for JSON
+			log.Printf("Format is not forwarded to /v1/completions (model %s)", ollamaReq.Model)
+		}
+		path = "/v1/completions"
+		completionReq := models.OpenAICompletionRequest{
+			Prompt: prompt,
+			Suffix: ollamaReq.Suffix,
+			Stream: stream,
+
+			OpenAISamplingParams: samplingParams,
+		}
+		if stream {
+			completionReq.StreamOptions = &models.OpenAIStreamOptions{IncludeUsage: true}
+		}
+		payload = func(target backends.Target) interface{} {
+			completionReq.Model = target.Model
+			return completionReq
+		}
+	} else {
+		history, err := decodeGenerateContext(ollamaReq.Context)
+		if err != nil {
+			// Most likely token IDs produced by a real Ollama server
+			log.Printf("Ignoring unrecognised generate context: %v", err)
+		}
+		messages, err = buildGenerateMessages(ollamaReq, history)
+		if err != nil {
+			http.Error(w, "Bad request: Invalid image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		path = "/v1/chat/completions"
+		chatReq := models.OpenAIChatRequest{
+			Messages: messages,
+			Stream:   stream,
+
+			ResponseFormat:       responseFormat,
+			OpenAISamplingParams: samplingParams,
+		}
+		if stream {
+			chatReq.StreamOptions = &models.OpenAIStreamOptions{IncludeUsage: true}
+		}
+		payload = func(target backends.Target) interface{} {
+			chatReq.Model = target.Model
+			return chatReq
+		}
+	}
+
+	inFlight := registry.BeginRequest(ollamaReq.Model, keepAlive)
+	defer inFlight.End()
+	resp, err := postWithFailover(r.Context(), targets, r.Header.Get("Authorization"), path, stream, payload)
+	if err != nil {
+		if clientDisconnected(r.Context(), ollamaReq.Model) {
+			return
+		}
+		log.Printf("Error making request to OpenAI: %v", err)
+		http.Error(w, "Failed to communicate with OpenAI API", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		forwardOpenAIError(w, resp)
+		return
+	}
+	inFlight.Accept()
+
+	if stream {
+		streamGenerateResponse(r.Context(), w, resp.Body, ollamaReq.Model, completions, messages, validateFormat, timer)
+		return
+	}
+
+	respBodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if clientDisconnected(r.Context(), ollamaReq.Model) {
+			return
+		}
+		log.Printf("Error reading OpenAI response body: %v", err)
+		http.Error(w, "Failed to read response from OpenAI", http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		text, finishReason string
+		created            int64
+		usage              *models.OpenAIUsage
+	)
+	if completions {
+		var openAIResp models.OpenAICompletionResponse
+		if err := json.Unmarshal(respBodyBytes, &openAIResp); err != nil || len(openAIResp.Choices) == 0 {
+			log.Printf("Unexpected OpenAI completion response: %v. Body: %s", err, string(respBodyBytes))
+			http.Error(w, "Failed to decode OpenAI response", http.StatusInternalServerError)
+			return
+		}
+		text, created = openAIResp.Choices[0].Text, openAIResp.Created
+		finishReason, usage = openAIResp.Choices[0].FinishReason, openAIResp.Usage
+	} else {
+		var openAIResp models.OpenAIChatResponse
+		if err := json.Unmarshal(respBodyBytes, &openAIResp); err != nil || len(openAIResp.Choices) == 0 {
+			log.Printf("Unexpected OpenAI chat response: %v. Body: %s", err, string(respBodyBytes))
+			http.Error(w, "Failed to decode OpenAI response", http.StatusInternalServerError)
+			return
+		}
+		text, created = openAIResp.Choices[0].Message.Content, openAIResp.Created
+		finishReason, usage = openAIResp.Choices[0].FinishReason, openAIResp.Usage
+	}
+
+	if validateFormat != nil {
+		if err := checkFormat(validateFormat, text); err != nil {
+			log.Printf("Structured output validation failed for %s: %v", ollamaReq.Model, err)
+			writeOllamaError(w, http.StatusBadGateway, formatMismatchMessage(err))
+			return
+		}
+	}
+
+	ollamaResp := models.OllamaGenerateResponse{
+		Model:     ollamaReq.Model,
+		CreatedAt: time.Unix(created, 0).UTC().Format(time.RFC3339),
+		Response:  text,
+		Done:      true,
+
+		OllamaMetrics: timer.metrics(usage, finishReason),
+	}
+	if !completions {
+		ollamaResp.Context = encodeGenerateContext(append(messages, models.OpenAIChatMessage{Role: "assistant", Content: text}))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ollamaResp); err != nil {
+		log.Printf("Error encoding Ollama generate response: %v", err)
+	}
+}
+
+// streamGenerateResponse converts an OpenAI SSE stream from either endpoint
+// into Ollama generate chunks. A response that doesn't match format ends the
+// stream with an error line instead of the final chunk with usage and timings.
+// Cancelling ctx aborts the upstream stream.
+func streamGenerateResponse(ctx context.Context, w http.ResponseWriter, body io.Reader, model string, completions bool, messages []models.OpenAIChatMessage, format json.RawMessage, timer *requestTimer) {
+	flusher, ok := startNDJSONStream(w)
+	if !ok {
+		return
+	}
+
+	var fullText strings.Builder
+	var usage *models.OpenAIUsage
+	var finishReason string
+	events := newSSEReader(body)
+	for {
+		event, err := events.Next()
+		if err != nil {
+			if err != io.EOF && !clientDisconnected(ctx, model) {
+				log.Printf("Error reading stream from OpenAI: %v", err)
+			}
+			return
+		}
+
+		if event.Data == "[DONE]" {
+			if format != nil {
+				if err := checkFormat(format, fullText.String()); err != nil {
+					log.Printf("Structured output validation failed for %s: %v", model, err)
+					if err := writeNDJSON(w, flusher, models.OllamaErrorResponse{Error: formatMismatchMessage(err)}); err != nil {
+						log.Printf("Error writing stream error: %v", err)
+					}
+					return
+				}
+			}
+			finalChunk := models.OllamaGenerateResponse{
+				Model:     model,
+				CreatedAt: time.Now().UTC().Format(time.RFC3339),
+				Done:      true,
+
+				OllamaMetrics: timer.metrics(usage, finishReason),
+			}
+			if !completions {
+				finalChunk.Context = encodeGenerateContext(append(messages, models.OpenAIChatMessage{Role: "assistant", Content: fullText.String()}))
+			}
+			if err := writeNDJSON(w, flusher, finalChunk); err != nil {
+				log.Printf("Error writing final generate chunk: %v", err)
+			}
+			return
+		}
+
+		chunk, err := parseStreamChunk(event.Data, completions)
+		if err != nil {
+			log.Printf("Error unmarshalling OpenAI stream chunk '%s': %v", event.Data, err)
+			continue
+		}
+		if chunk.usage != nil {
+			usage = chunk.usage
+		}
+		if chunk.finishReason != "" {
+			finishReason = chunk.finishReason
+		}
+		if chunk.text == "" {
+			continue
+		}
+		fullText.WriteString(chunk.text)
+		timer.token()
+
+		ollamaChunk := models.OllamaGenerateResponse{
+			Model:     model,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			Response:  chunk.text,
+		}
+		if err := writeNDJSON(w, flusher, ollamaChunk); err != nil {
+			if !clientDisconnected(ctx, model) {
+				log.Printf("Error writing Ollama generate chunk: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// streamChunk is the part of a chat or completion stream chunk /api/generate uses.
+type streamChunk struct {
+	text         string
+	finishReason string
+	usage        *models.OpenAIUsage
+}
+
+// parseStreamChunk decodes a chat or completion stream chunk.
+func parseStreamChunk(data string, completions bool) (streamChunk, error) {
+	if completions {
+		var chunk models.OpenAICompletionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return streamChunk{}, err
+		}
+		parsed := streamChunk{usage: chunk.Usage}
+		if len(chunk.Choices) > 0 {
+			parsed.text, parsed.finishReason = chunk.Choices[0].Text, chunk.Choices[0].FinishReason
+		}
+		return parsed, nil
+	}
+
+	var chunk models.OpenAIStreamChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return streamChunk{}, err
+	}
+	parsed := streamChunk{usage: chunk.Usage}
+	if len(chunk.Choices) > 0 {
+		parsed.text, parsed.finishReason = chunk.Choices[0].Delta.Content, chunk.Choices[0].FinishReason
+	}
+	return parsed, nil
+}
+
+// useCompletionsAPI reports whether the request needs a plain text prompt
+// rather than chat messages.
+func useCompletionsAPI(req models.OllamaGenerateRequest) bool {
+	return req.Raw || req.Suffix != "" || req.Template != ""
+}
+
+// buildCompletionPrompt returns the prompt for /v1/completions. Custom
+// templates are rendered with Ollama's .System, .Prompt, .Suffix and
+// .Response variables and cut off where the response would start.
+func buildCompletionPrompt(req models.OllamaGenerateRequest) (string, error) {
+	if req.Raw || req.Template == "" {
+		return req.Prompt, nil
+	}
+
+	tmpl, err := template.New("prompt").Parse(req.Template)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	data := map[string]string{
+		"System":   req.System,
+		"Prompt":   req.Prompt,
+		"Suffix":   req.Suffix,
+		"Response": responsePlaceholder,
+	}
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", err
+	}
+	prompt, _, _ := strings.Cut(rendered.String(), responsePlaceholder)
+	return prompt, nil
+}
+
+// buildGenerateMessages turns a generate request and the conversation restored
+// from its context into chat messages. A new system prompt replaces the old one.
+func buildGenerateMessages(req models.OllamaGenerateRequest, history []models.OpenAIChatMessage) ([]models.OpenAIChatMessage, error) {
+	var messages []models.OpenAIChatMessage
+	if req.System != "" {
+		messages = append(messages, models.OpenAIChatMessage{Role: "system", Content: req.System})
+	}
+	for _, msg := range history {
+		if msg.Role == "system" && req.System != "" {
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	prompt := models.OpenAIChatMessage{Role: "user", Content: req.Prompt}
+	if len(req.Images) > 0 {
+		parts, err := contentParts(req.Prompt, req.Images)
+		if err != nil {
+			return nil, err
+		}
+		prompt.ContentParts = parts
+	}
+	return append(messages, prompt), nil
+}
+
+// encodeGenerateContext packs the conversation into Ollama's `context` field.
+// OpenAI-compatible APIs don't expose token IDs, so the context carries the
+// bytes of the JSON encoded messages instead. Clients treat it as opaque and
+// send it back unchanged with the next prompt. Images are not kept.
+func encodeGenerateContext(messages []models.OpenAIChatMessage) []int {
+	textOnly := make([]models.OpenAIChatMessage, len(messages))
+	for i, msg := range messages {
+		msg.ContentParts = nil
+		textOnly[i] = msg
+	}
+	encoded, err := json.Marshal(textOnly)
+	if err != nil {
+		log.Printf("Error encoding generate context: %v", err)
+		return nil
+	}
+	context := make([]int, len(encoded))
+	for i, b := range encoded {
+		context[i] = int(b)
+	}
+	return context
+}
+
+// decodeGenerateContext restores the messages stored by encodeGenerateContext.
+func decodeGenerateContext(context []int) ([]models.OpenAIChatMessage, error) {
+	if len(context) == 0 {
+		return nil, nil
+	}
+	encoded := make([]byte, len(context))
+	for i, v := range context {
+		if v < 0 || v > 255 {
+			return nil, fmt.Errorf("context value %d at position %d is not a byte", v, i)
+		}
+		encoded[i] = byte(v)
+	}
+	var messages []models.OpenAIChatMessage
+	if err := json.Unmarshal(encoded, &messages); err != nil {
+		return nil, errors.New("context does not contain proxy messages")
+	}
+	return messages, nil
+}
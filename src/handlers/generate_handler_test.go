@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/models"
+)
+
+func newGenerateRequest(t *testing.T, payload models.OllamaGenerateRequest) *http.Request {
+	t.Helper()
+	reqBytes, _ := json.Marshal(payload)
+	req, _ := http.NewRequest("POST", "/api/generate", bytes.NewBuffer(reqBytes))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestGenerateHandler_NonStreaming_ChatWithContext(t *testing.T) {
+	var openAIRequests []models.OpenAIChatRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Mock OpenAI: unexpected path %s", r.URL.Path)
+		}
+		var openAIReq models.OpenAIChatRequest
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		openAIRequests = append(openAIRequests, openAIReq)
+
+		answer := "Paris"
+		if len(openAIRequests) == 2 {
+			answer = "About 2 million"
+		}
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Model:   openAIReq.Model,
+			Created: 1700000000,
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: answer}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	noStream := false
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{
+		Model:  "gpt-4o",
+		System: "Be brief",
+		Prompt: "Capital of France?",
+		Stream: &noStream,
+	}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var first models.OllamaGenerateResponse
+	if err := json.NewDecoder(rr.Body).Decode(&first); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if first.Response != "Paris" || !first.Done || len(first.Context) == 0 {
+		t.Fatalf("Unexpected response: %+v", first)
+	}
+	if msgs := openAIRequests[0].Messages; len(msgs) != 2 || msgs[0].Role != "system" || msgs[1].Content != "Capital of France?" {
+		t.Errorf("Unexpected upstream messages: %+v", msgs)
+	}
+
+	// Sending the context back continues the conversation
+	rr = httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{
+		Model:   "gpt-4o",
+		Prompt:  "Population?",
+		Context: first.Context,
+		Stream:  &noStream,
+	}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	msgs := openAIRequests[1].Messages
+	if len(msgs) != 4 || msgs[2].Content != "Paris" || msgs[3].Content != "Population?" {
+		t.Errorf("Context was not restored into upstream messages: %+v", msgs)
+	}
+}
+
+func TestGenerateHandler_Streaming(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"Hel"}}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"lo"}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	rr := httptest.NewRecorder()
+	// Stream is omitted: Ollama streams by default
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{Model: "gpt-4o", Prompt: "Hi"}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("Expected Content-Type application/x-ndjson, got %s", contentType)
+	}
+	var chunks []models.OllamaGenerateResponse
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		var chunk models.OllamaGenerateResponse
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			t.Fatalf("Could not unmarshal chunk '%s': %v", scanner.Text(), err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[0].Response != "Hel" || chunks[1].Response != "lo" || chunks[0].Done {
+		t.Errorf("Unexpected content chunks: %+v", chunks[:2])
+	}
+	if !chunks[2].Done || len(chunks[2].Context) == 0 {
+		t.Errorf("Expected final chunk with context, got %+v", chunks[2])
+	}
+	history, err := decodeGenerateContext(chunks[2].Context)
+	if err != nil || len(history) != 2 || history[1].Content != "Hello" {
+		t.Errorf("Unexpected context history %+v (err: %v)", history, err)
+	}
+}
+
+func TestGenerateHandler_FillInTheMiddleUsesCompletions(t *testing.T) {
+	var openAIReq models.OpenAICompletionRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/completions" {
+			t.Errorf("Mock OpenAI: expected /v1/completions, got %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"text":"return a + b"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{
+		Model:  "codestral",
+		Prompt: "def add(a, b):\n    ",
+		Suffix: "\n\nprint(add(1, 2))",
+	}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if openAIReq.Prompt != "def add(a, b):\n    " || openAIReq.Suffix != "\n\nprint(add(1, 2))" || !openAIReq.Stream {
+		t.Errorf("Unexpected completion request: %+v", openAIReq)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d: %s", len(lines), rr.Body.String())
+	}
+	var chunk models.OllamaGenerateResponse
+	json.Unmarshal([]byte(lines[0]), &chunk)
+	if chunk.Response != "return a + b" {
+		t.Errorf("Unexpected chunk: %+v", chunk)
+	}
+	json.Unmarshal([]byte(lines[1]), &chunk)
+	if !chunk.Done || chunk.Context != nil {
+		t.Errorf("Expected final chunk without context, got %+v", chunk)
+	}
+}
+
+func TestGenerateHandler_TemplateIsRendered(t *testing.T) {
+	var openAIReq models.OpenAICompletionRequest
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAICompletionResponse{Choices: []models.OpenAICompletionChoice{{Text: "4"}}})
+	}))
+	defer mockOpenAIServer.Close()
+
+	noStream := false
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{
+		Model:    "base-model",
+		System:   "Math tutor",
+		Prompt:   "2+2?",
+		Template: "[SYS]{{ .System }}[/SYS] Q: {{ .Prompt }} A: {{ .Response }}</s>",
+		Stream:   &noStream,
+	}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if expected := "[SYS]Math tutor[/SYS] Q: 2+2? A: "; openAIReq.Prompt != expected {
+		t.Errorf("Unexpected rendered prompt: got %q want %q", openAIReq.Prompt, expected)
+	}
+	var resp models.OllamaGenerateResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Response != "4" || !resp.Done {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+}
+
+func TestGenerateHandler_EmptyPromptLoadsModel(t *testing.T) {
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{Model: "gpt-4o"}), testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp models.OllamaGenerateResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if !resp.Done || resp.Model != "gpt-4o" || resp.Response != "" {
+		t.Errorf("Unexpected load response: %+v", resp)
+	}
+}
+
+func TestGenerateHandler_OpenAIError(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"message":"The model does not exist","code":"model_not_found"}}`)
+	}))
+	defer mockOpenAIServer.Close()
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{Model: "missing", Prompt: "Hi"}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusNotFound, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "model_not_found") {
+		t.Errorf("Expected upstream error to be forwarded, got: %s", rr.Body.String())
+	}
+}
+
+func TestGenerateHandler_MissingAuthHeader(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(`{"model":"gpt-4o","prompt":"Hi"}`))
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestGenerateHandler_MethodNotAllowed(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/generate", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig("http://dummyurl"), testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Handler returned wrong status: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestGenerateHandler_Images(t *testing.T) {
+	var requestBody string
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requestBody = string(body)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: "A cat"}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	noStream := false
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, newGenerateRequest(t, models.OllamaGenerateRequest{
+		Model:  "gpt-4o",
+		Prompt: "Describe",
+		Images: []string{testJPEG},
+		Stream: &noStream,
+	}), testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status: got %v want %v. Body: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if !strings.Contains(requestBody, `"image_url":{"url":"data:image/jpeg;base64,`) {
+		t.Errorf("Expected image content part in upstream request, got %s", requestBody)
+	}
+
+	var resp models.OllamaGenerateResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	history, err := decodeGenerateContext(resp.Context)
+	if err != nil || len(history) != 2 || history[0].Content != "Describe" {
+		t.Errorf("Expected text-only context, got %+v (err: %v)", history, err)
+	}
+}
+
+func TestGenerateHandler_FormatValidation(t *testing.T) {
+	var openAIReq map[string]interface{}
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		json.NewEncoder(w).Encode(models.OpenAIChatResponse{
+			Choices: []models.OpenAIChatChoice{{Message: models.OpenAIChatMessage{Role: "assistant", Content: `{"colors":"red"}`}}},
+		})
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-4o","prompt":"Colors?","stream":false,"format":{"type":"object","properties":{"colors":{"type":"array"}}}}`
+	req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.ValidateStructuredOutput = true
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, cfg, testRegistry(t, openAIBackend(cfg.OpenAIBaseURL)))
+
+	if responseFormat, _ := openAIReq["response_format"].(map[string]interface{}); responseFormat["type"] != "json_schema" {
+		t.Errorf("Expected json_schema response format, got %+v", openAIReq["response_format"])
+	}
+	if rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "$.colors: expected array, got string") {
+		t.Errorf("Expected format mismatch error, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestGenerateHandler_StreamingMetricsWithoutUsage(t *testing.T) {
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"choices":[{"index":0,"text":"Hel"}]}`+"\n\n")
+		io.WriteString(w, `data: {"choices":[{"index":0,"text":"lo","finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+
+	body := `{"model":"gpt-3.5-turbo-instruct","prompt":"Say hello","raw":true}`
+	req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	var last models.OllamaGenerateResponse
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || last.OllamaMetrics == nil {
+		t.Fatalf("Expected final chunk with metrics, got %s", lines[len(lines)-1])
+	}
+	// Without usage from the upstream, every content chunk counts as a token
+	if last.DoneReason != "stop" || last.EvalCount != 2 || last.PromptEvalCount != 0 {
+		t.Errorf("Unexpected metrics: %+v", *last.OllamaMetrics)
+	}
+}
+
+func TestGenerateHandler_ClientGoneBeforeResponse(t *testing.T) {
+	upstreamClosed := make(chan struct{})
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // The server only notices a closed connection once the body is read
+		// A slow model that hasn't answered yet
+		select {
+		case <-r.Context().Done():
+			close(upstreamClosed)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer mockOpenAIServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	body := `{"model":"gpt-4o","prompt":"Hello","stream":false}`
+	req, _ := http.NewRequestWithContext(ctx, "POST", "/api/generate", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+
+	rr := httptest.NewRecorder()
+	GenerateHandler(rr, req, testConfig(mockOpenAIServer.URL), testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
+
+	select {
+	case <-upstreamClosed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Upstream request was not cancelled")
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected no answer to a client that is gone, got %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// errImagesNotSupported is returned for images sent to a text-only model
+// when the image policy is "reject".
+var errImagesNotSupported = errors.New("model does not support images")
+
+// applyImagePolicy removes images from the messages, or rejects them, when
+// model is configured as text-only.
+func applyImagePolicy(messages []models.OllamaChatMessage, model string, cfg config.AppConfig) ([]models.OllamaChatMessage, error) {
+	if !matchesModel(model, cfg.TextOnlyModels) {
+		return messages, nil
+	}
+
+	stripped := make([]models.OllamaChatMessage, len(messages))
+	for i, msg := range messages {
+		if len(msg.Images) > 0 {
+			if cfg.TextOnlyImagePolicy != "strip" {
+				return nil, fmt.Errorf("%w: %s", errImagesNotSupported, model)
+			}
+			log.Printf("Stripping %d image(s) sent to text-only model %s", len(msg.Images), model)
+			msg.Images = nil
+		}
+		stripped[i] = msg
+	}
+	return stripped, nil
+}
+
+// matchesModel reports whether model matches one of the glob patterns, e.g. gpt-3.5-*.
+func matchesModel(model string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, model); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// contentParts builds OpenAI content parts from a message text and its
+// base64 encoded images.
+func contentParts(text string, images []string) ([]models.OpenAIContentPart, error) {
+	parts := make([]models.OpenAIContentPart, 0, len(images)+1)
+	if text != "" {
+		parts = append(parts, models.OpenAIContentPart{Type: "text", Text: text})
+	}
+	for i, image := range images {
+		url, err := imageDataURI(image)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i, err)
+		}
+		parts = append(parts, models.OpenAIContentPart{Type: "image_url", ImageURL: &models.OpenAIImageURL{URL: url}})
+	}
+	return parts, nil
+}
+
+// imageDataURI turns an Ollama base64 image into a data URI. Ollama clients
+// don't send a MIME type, so it is detected from the image bytes.
+func imageDataURI(image string) (string, error) {
+	if strings.HasPrefix(image, "data:image/") {
+		return image, nil // Already a data URI
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(image)
+	if err != nil {
+		return "", errors.New("invalid base64 data")
+	}
+	mimeType := http.DetectContentType(decoded)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unsupported image type %s", mimeType)
+	}
+	return "data:" + mimeType + ";base64," + image, nil
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var (
+	testPNG  = base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	testJPEG = base64.StdEncoding.EncodeToString([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"))
+)
+
+func TestImageDataURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    string
+		expected string
+		errText  string
+	}{
+		{name: "png", image: testPNG, expected: "data:image/png;base64," + testPNG},
+		{name: "jpeg", image: testJPEG, expected: "data:image/jpeg;base64," + testJPEG},
+		{name: "data uri", image: "data:image/webp;base64,AAAA", expected: "data:image/webp;base64,AAAA"},
+		{name: "invalid base64", image: "not base64!", errText: "invalid base64"},
+		{name: "not an image", image: base64.StdEncoding.EncodeToString([]byte("plain text")), errText: "unsupported image type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, err := imageDataURI(tt.image)
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if uri != tt.expected {
+				t.Errorf("Unexpected data URI: got %s want %s", uri, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsTextOnlyModel(t *testing.T) {
+	patterns := []string{"gpt-3.5-*", "o1-mini"}
+	if !matchesModel("gpt-3.5-turbo", patterns) || !matchesModel("o1-mini", patterns) {
+		t.Error("Expected models matching the patterns to be text-only")
+	}
+	if matchesModel("gpt-4o", patterns) {
+		t.Error("Expected gpt-4o not to be text-only")
+	}
+}
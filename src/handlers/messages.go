@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+
+	"ollama-openai-proxy/src/models"
+)
+
+// toOpenAIMessages converts Ollama chat messages into OpenAI messages.
+// Images become image_url content parts. Ollama doesn't require IDs on tool
+// calls, but OpenAI needs every "tool" message to reference the call it
+// answers. Missing IDs are generated and tool results are matched to the
+// preceding assistant calls by tool name, or in order when no name is given.
+func toOpenAIMessages(messages []models.OllamaChatMessage) ([]models.OpenAIChatMessage, error) {
+	openAIMessages := make([]models.OpenAIChatMessage, len(messages))
+	var pending []models.OpenAIToolCall // Calls of the last assistant message without a result yet
+
+	for i, msg := range messages {
+		openAIMsg := models.OpenAIChatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		if len(msg.Images) > 0 {
+			parts, err := contentParts(msg.Content, msg.Images)
+			if err != nil {
+				return nil, fmt.Errorf("message %d: %w", i, err)
+			}
+			openAIMsg.ContentParts = parts
+		}
+
+		switch {
+		case len(msg.ToolCalls) > 0:
+			pending = pending[:0]
+			for j, call := range msg.ToolCalls {
+				id := call.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				openAICall := models.OpenAIToolCall{
+					ID:   id,
+					Type: "function",
+					Function: models.OpenAIToolCallFunction{
+						Name:      call.Function.Name,
+						Arguments: toolArgumentsString(call.Function.Arguments),
+					},
+				}
+				openAIMsg.ToolCalls = append(openAIMsg.ToolCalls, openAICall)
+				pending = append(pending, openAICall)
+			}
+		case msg.Role == "tool":
+			match := -1
+			for j, call := range pending {
+				if msg.ToolName == "" || call.Function.Name == msg.ToolName {
+					match = j
+					break
+				}
+			}
+			if match >= 0 {
+				openAIMsg.ToolCallID = pending[match].ID
+				pending = append(pending[:match], pending[match+1:]...)
+			} else {
+				log.Printf("Tool message for %q does not match any preceding tool call", msg.ToolName)
+			}
+		}
+
+		openAIMessages[i] = openAIMsg
+	}
+	return openAIMessages, nil
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"time"
+
+	"ollama-openai-proxy/src/models"
+)
+
+// requestTimer measures the timings Ollama reports on its final message.
+// OpenAI doesn't expose how long the model spent on the prompt, so the time
+// until the first streamed token stands in for prompt evaluation and the rest
+// counts as generation. Load duration is always zero: there is nothing to load.
+type requestTimer struct {
+	start      time.Time
+	firstToken time.Time
+	chunks     int // Content chunks seen, used when the upstream reports no usage
+}
+
+func newRequestTimer() *requestTimer {
+	return &requestTimer{start: time.Now()}
+}
+
+// token records that a chunk of generated content arrived.
+func (t *requestTimer) token() {
+	if t.firstToken.IsZero() {
+		t.firstToken = time.Now()
+	}
+	t.chunks++
+}
+
+// metrics builds the final statistics from the upstream usage, which may be nil,
+// and the OpenAI finish reason.
+func (t *requestTimer) metrics(usage *models.OpenAIUsage, finishReason string) *models.OllamaMetrics {
+	end := time.Now()
+	m := &models.OllamaMetrics{
+		DoneReason:    ollamaDoneReason(finishReason),
+		TotalDuration: end.Sub(t.start).Nanoseconds(),
+		EvalDuration:  end.Sub(t.start).Nanoseconds(),
+	}
+	if !t.firstToken.IsZero() {
+		m.PromptEvalDuration = t.firstToken.Sub(t.start).Nanoseconds()
+		m.EvalDuration = end.Sub(t.firstToken).Nanoseconds()
+	}
+	if usage != nil {
+		m.PromptEvalCount = usage.PromptTokens
+		m.EvalCount = usage.CompletionTokens
+	} else {
+		// Streamed deltas are usually one token each
+		m.EvalCount = t.chunks
+	}
+	return m
+}
+
+// ollamaDoneReason maps an OpenAI finish_reason onto Ollama's done_reason.
+// Ollama only distinguishes running out of tokens from stopping.
+func ollamaDoneReason(finishReason string) string {
+	if finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
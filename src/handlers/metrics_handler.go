@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// cancelledRequests counts upstream requests aborted because the client went
+// away, see clientDisconnected.
+var cancelledRequests atomic.Int64
+
+// MetricsHandler handles requests to /debug/vars with the proxy's counters.
+// Unlike expvar's handler, it doesn't reveal the command line or memory
+// statistics of the process.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]int64{
+		"cancelled_upstream_requests": cancelledRequests.Load(),
+	}); err != nil {
+		log.Printf("Error encoding metrics: %v", err)
+	}
+}
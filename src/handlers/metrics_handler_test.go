@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/debug/vars", nil)
+	rr := httptest.NewRecorder()
+	MetricsHandler(rr, req)
+
+	var metrics map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&metrics); err != nil {
+		t.Fatalf("Could not decode metrics: %v", err)
+	}
+	if _, ok := metrics["cancelled_upstream_requests"]; !ok || len(metrics) != 1 {
+		t.Errorf("Expected only the proxy's counters, got %v", metrics)
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+)
+
+// postToOpenAI marshals payload and sends it to apiURL on backend,
+// authenticated with authToken. The request is bound to ctx, normally the
+// incoming request's context, so a client disconnect aborts it.
+func postToOpenAI(ctx context.Context, backend *backends.Backend, apiURL, authToken string, payload interface{}, stream bool) (*http.Response, error) {
+	reqBodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling OpenAI request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating request to OpenAI: %w", err)
+	}
+	setAuthHeaders(httpReq, backend, authToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := backend.Client.Do(httpReq, stream)
+	if err != nil {
+		return nil, fmt.Errorf("making request to OpenAI: %w", err)
+	}
+	return resp, nil
+}
+
+// routeModel resolves model to the targets to try, in order, leaving out
+// those there are no credentials for. On failure it answers the client itself
+// and reports false.
+func routeModel(w http.ResponseWriter, registry *backends.Registry, model, clientAuth string) ([]backends.Target, bool) {
+	targets, status, err := resolveTargets(registry, model, clientAuth)
+	switch {
+	case err == nil:
+		return targets, true
+	case status == http.StatusNotFound:
+		writeOllamaError(w, status, err.Error())
+	default:
+		http.Error(w, err.Error(), status)
+	}
+	return nil, false
+}
+
+// resolveTargets is routeModel without answering the client. On failure it
+// returns the status to answer with.
+func resolveTargets(registry *backends.Registry, model, clientAuth string) ([]backends.Target, int, error) {
+	targets, err := registry.Resolve(model)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	var usable []backends.Target
+	for _, target := range targets {
+		if target.Backend.Authorization(clientAuth) != "" {
+			usable = append(usable, target)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, http.StatusUnauthorized, errors.New("Unauthorized: Missing Authorization header")
+	}
+	return usable, http.StatusOK, nil
+}
+
+// postWithFailover sends the payload built for each target to path on its
+// backend until one answers without a connection error or 5xx status. Since
+// only the response headers are awaited, failing over never happens after
+// anything was streamed to the client. The outcome of every attempt feeds the
+// backend's health tracking. The last failure is returned when all targets
+// fail. The backend counts the request as in flight until the response body
+// is closed.
+func postWithFailover(ctx context.Context, targets []backends.Target, clientAuth, path string, stream bool, payload func(backends.Target) interface{}) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i, target := range targets {
+		backend := target.Backend
+		if i > 0 {
+			log.Printf("Failing over to backend %s for %s", backend.Name, target.Model)
+		}
+		done := backend.Begin()
+		resp, err = postToBackend(ctx, target, path, backend.Authorization(clientAuth), payload(target), stream)
+		if err == nil && resp.StatusCode < 500 {
+			backend.RecordSuccess()
+			resp.Body = &doneOnClose{ReadCloser: resp.Body, done: done}
+			return resp, nil
+		}
+		if err != nil && ctx.Err() != nil {
+			done()
+			return nil, err // The client went away, the backend is not to blame
+		}
+
+		// 501 means the backend lacks the endpoint, not that it is unwell
+		if err != nil || resp.StatusCode != http.StatusNotImplemented {
+			backend.RecordFailure()
+		}
+		if err != nil {
+			log.Printf("Backend %s failed for %s: %v", backend.Name, target.Model, err)
+			done()
+			continue
+		}
+		log.Printf("Backend %s failed for %s: %s", backend.Name, target.Model, resp.Status)
+		if i < len(targets)-1 {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			done()
+			continue
+		}
+		resp.Body = &doneOnClose{ReadCloser: resp.Body, done: done}
+	}
+	return resp, err
+}
+
+// doneOnClose ends an in-flight request together with its response body.
+type doneOnClose struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *doneOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
+
+// postToBackend sends an OpenAI request payload for target to path on its
+// backend, through the adapter of the backend's API if it isn't
+// OpenAI-compatible.
+func postToBackend(ctx context.Context, target backends.Target, path, authToken string, payload interface{}, stream bool) (*http.Response, error) {
+	backend := target.Backend
+	switch backend.Type {
+	case config.BackendTypeAnthropic:
+		return postToAnthropic(ctx, backend, path, authToken, payload, stream)
+	case config.BackendTypeGemini:
+		return postToGemini(ctx, backend, path, authToken, payload, stream)
+	case config.BackendTypeAzure:
+		return postToOpenAI(ctx, backend, azureURL(backend, target.Model, path), authToken, payload, stream)
+	}
+	return postToOpenAI(ctx, backend, backend.BaseURL+path, authToken, payload, stream)
+}
+
+// clientDisconnected reports whether ctx was cancelled because the client went
+// away, logging and counting the aborted upstream request. There is nobody
+// left to answer in that case.
+func clientDisconnected(ctx context.Context, model string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	cancelledRequests.Add(1)
+	log.Printf("Client disconnected, cancelled upstream request for %s", model)
+	return true
+}
+
+// forwardOpenAIError relays a non-200 OpenAI response to the client, keeping
+// the upstream status code and its JSON error body when there is one.
+func forwardOpenAIError(w http.ResponseWriter, resp *http.Response) {
+	respBodyBytes, _ := io.ReadAll(resp.Body)
+	log.Printf("OpenAI API Error: Status %d, Body: %s", resp.StatusCode, string(respBodyBytes))
+	var errorResp map[string]interface{}
+	if json.Unmarshal(respBodyBytes, &errorResp) == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.StatusCode)
+		json.NewEncoder(w).Encode(errorResp)
+		return
+	}
+	http.Error(w, "OpenAI API request failed: "+resp.Status, resp.StatusCode)
+}
+
+// startNDJSONStream prepares w for an Ollama NDJSON stream. It reports false
+// (after answering the client) when w cannot be flushed.
+func startNDJSONStream(w http.ResponseWriter) (http.Flusher, bool) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// w.WriteHeader(http.StatusOK) // Implicitly called on first Write/Flush
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Streaming unsupported: Flusher not available")
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return nil, false
+	}
+	return flusher, true
+}
+
+// writeNDJSON writes v as a single NDJSON line and flushes it to the client.
+func writeNDJSON(w http.ResponseWriter, flusher http.Flusher, v interface{}) error {
+	// Encode already terminates the line with '\n'
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// OpenAIHandler handles the OpenAI-compatible endpoints Ollama serves as well:
+// /v1/chat/completions, /v1/completions and /v1/embeddings. Requests are
+// routed like those of the Ollama endpoints, with the model name replaced by
+// the one the chosen backend knows, but otherwise passed through as they are.
+// The backend's answer, including errors, is relayed unchanged. Backends with
+// an adapter (Anthropic, Gemini) only support chat completions.
+func OpenAIHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+	path := r.URL.Path
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Bad request: "+err.Error())
+		return
+	}
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Bad request: "+err.Error())
+		return
+	}
+	var model string
+	if err := json.Unmarshal(request["model"], &model); err != nil || model == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Bad request: model is required")
+		return
+	}
+	var stream bool
+	if raw, ok := request["stream"]; ok {
+		json.Unmarshal(raw, &stream)
+	}
+
+	clientAuth := r.Header.Get("Authorization")
+	targets, status, err := resolveTargets(registry, model, clientAuth)
+	if err != nil {
+		errType := "invalid_request_error"
+		if status == http.StatusUnauthorized {
+			errType = "authentication_error"
+		}
+		writeOpenAIError(w, status, errType, err.Error())
+		return
+	}
+
+	// Adapters convert typed chat requests; OpenAI-compatible backends get
+	// the request as it was sent
+	var chatReq models.OpenAIChatRequest
+	if path == "/v1/chat/completions" && needsAdapter(targets) {
+		if err := json.Unmarshal(body, &chatReq); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "Bad request: "+err.Error())
+			return
+		}
+	}
+	payload := func(target backends.Target) interface{} {
+		if path == "/v1/chat/completions" && needsAdapter([]backends.Target{target}) {
+			targetReq := chatReq
+			targetReq.Model = target.Model
+			return targetReq
+		}
+		targetReq := make(map[string]json.RawMessage, len(request))
+		for key, value := range request {
+			targetReq[key] = value
+		}
+		targetReq["model"], _ = json.Marshal(target.Model)
+		return targetReq
+	}
+
+	inFlight := registry.BeginRequest(model, nil)
+	defer inFlight.End()
+	resp, err := postWithFailover(r.Context(), targets, clientAuth, path, stream, payload)
+	if err != nil {
+		if clientDisconnected(r.Context(), model) {
+			return
+		}
+		log.Printf("Error forwarding %s request: %v", path, err)
+		writeOpenAIError(w, http.StatusBadGateway, "api_error", "Failed to reach the upstream API")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		inFlight.Accept()
+	}
+	relayResponse(w, resp)
+}
+
+// OpenAIModelsHandler handles requests to /v1/models with the same models
+// /api/tags lists, in OpenAI format.
+func OpenAIModelsHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
+	if r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "Method not allowed")
+		return
+	}
+
+	listed, failure := listModels(r, registry)
+	if failure != nil {
+		writeOpenAIError(w, failure.status, "api_error", failure.message)
+		return
+	}
+	openAIResp := models.OpenAIModelsResponse{Object: "list", Data: make([]models.OpenAIModel, len(listed))}
+	for i, model := range listed {
+		openAIResp.Data[i] = models.OpenAIModel{ID: model.id, Object: "model", Created: model.created, OwnedBy: model.ownedBy}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(openAIResp); err != nil {
+		log.Printf("Error encoding OpenAI models response: %v", err)
+	}
+}
+
+// needsAdapter reports whether any of targets is reached through an adapter.
+func needsAdapter(targets []backends.Target) bool {
+	for _, target := range targets {
+		switch target.Backend.Type {
+		case config.BackendTypeAnthropic, config.BackendTypeGemini:
+			return true
+		}
+	}
+	return false
+}
+
+// relayResponse copies resp to the client as it arrives, flushing after every
+// read so that streamed events aren't held back.
+func relayResponse(w http.ResponseWriter, resp *http.Response) {
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.WriteHeader(resp.StatusCode)
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return // The client went away
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error relaying upstream response: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// writeOpenAIError answers the client with an OpenAI style error body.
+func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	body := map[string]interface{}{
+		"error": map[string]string{"message": message, "type": errType},
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding OpenAI error response: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+func TestOpenAIHandler_PassesRequestThrough(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer testtoken" {
+			t.Errorf("Unexpected request %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "kept private")
+		io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hi!"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"Hello"}]}],"tool_choice":"none","logprobs":true}`
+	req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, testRegistry(t, openAIBackend(server.URL, "gpt-*")))
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"content":"Hi!"`) {
+		t.Errorf("Unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Upstream") != "" {
+		t.Error("Expected upstream headers other than Content-Type to be dropped")
+	}
+	// Fields the proxy doesn't know are forwarded untouched
+	if received["tool_choice"] != "none" || received["logprobs"] != true {
+		t.Errorf("Unexpected upstream request: %v", received)
+	}
+}
+
+func TestOpenAIHandler_Streaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+	req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, testRegistry(t, openAIBackend(server.URL)))
+
+	expected := "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n"
+	if rr.Body.String() != expected || rr.Header().Get("Content-Type") != "text/event-stream" || !rr.Flushed {
+		t.Errorf("Unexpected stream %q (%s)", rr.Body.String(), rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestOpenAIHandler_UnknownModel(t *testing.T) {
+	body := `{"model":"llama3","input":"Hello"}`
+	req, _ := http.NewRequest("POST", "/v1/embeddings", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl", "gpt-*")))
+
+	var resp struct {
+		Error struct{ Message, Type string }
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusNotFound || resp.Error.Message != `model "llama3" not found` {
+		t.Errorf("Unexpected response %d: %+v", rr.Code, resp)
+	}
+}
+
+func TestOpenAIHandler_MissingModel(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/v1/completions", strings.NewReader(`{"prompt":"Hello"}`))
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl")))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", rr.Code)
+	}
+}
+
+func TestOpenAIHandler_GroupMemberModel(t *testing.T) {
+	var receivedModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var received struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&received)
+		receivedModel = received.Model
+		io.WriteString(w, `{"object":"list","data":[]}`)
+	}))
+	defer server.Close()
+
+	registry, err := backends.NewRegistry(config.AppConfig{
+		Backends: []config.BackendConfig{{Name: "openrouter", BaseURL: server.URL, APIKey: "sk-or"}},
+		Groups: []config.GroupConfig{{Name: "embedder", Members: []config.MemberConfig{
+			{Backend: "openrouter", Model: "openai/text-embedding-3-small", Weight: 1},
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", "/v1/embeddings", strings.NewReader(`{"model":"embedder","input":"Hello"}`))
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, registry)
+
+	if rr.Code != http.StatusOK || receivedModel != "openai/text-embedding-3-small" {
+		t.Errorf("Expected the member's model name upstream, got %q (%d)", receivedModel, rr.Code)
+	}
+}
+
+func TestOpenAIHandler_Adapter(t *testing.T) {
+	var received models.AnthropicMessagesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		io.WriteString(w, `{"id":"msg_1","model":"claude-sonnet-4","role":"assistant","content":[{"type":"text","text":"Hi!"}],
+			"stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	body := `{"model":"claude-sonnet-4","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":[{"type":"text","text":"Hello"}]}]}`
+	req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	OpenAIHandler(rr, req, testRegistry(t, anthropicBackend(server.URL)))
+
+	var resp models.OpenAIChatResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "Hi!" || resp.Usage.TotalTokens != 7 {
+		t.Errorf("Unexpected response %d: %+v", rr.Code, resp)
+	}
+	if received.System != "Be brief." || len(received.Messages) != 1 || received.Messages[0].Content[0].Text != "Hello" {
+		t.Errorf("Unexpected Anthropic request: %+v", received)
+	}
+}
+
+func TestOpenAIModelsHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"object":"list","data":[{"id":"gpt-4o","object":"model","created":1715367049,"owned_by":"system"},
+			{"id":"dall-e-3","object":"model","created":1698785189,"owned_by":"system"}]}`)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	OpenAIModelsHandler(rr, req, testRegistry(t, openAIBackend(server.URL, "gpt-*")))
+
+	var resp models.OpenAIModelsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp.Object != "list" || len(resp.Data) != 1 || resp.Data[0].ID != "gpt-4o" || resp.Data[0].Created != 1715367049 {
+		t.Errorf("Unexpected models: %+v", resp)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"ollama-openai-proxy/src/models"
+)
+
+// Translation of Ollama `options` into OpenAI sampling parameters:
+//
+//	Ollama option      OpenAI parameter                     Notes
+//	temperature        temperature                          0..2
+//	top_p              top_p                                0..1
+//	num_predict        max_tokens / max_completion_tokens   -1 (infinite) and -2 (fill context) are omitted
+//	stop               stop                                 string or array of strings
+//	seed               seed
+//	presence_penalty   presence_penalty                     -2..2
+//	frequency_penalty  frequency_penalty                    -2..2
+//	repeat_penalty     frequency_penalty                    approximated as repeat_penalty-1 when
+//	                                                        frequency_penalty is not set
+//	top_k              -                                    only sent to Anthropic and Gemini backends
+//
+// Options that only make sense for a local runtime (num_ctx, num_gpu, num_thread,
+// use_mmap, ...) and samplers OpenAI has no equivalent for (min_p, typical_p,
+// tfs_z, mirostat, ...) are ignored. Values of the wrong type or out
+// of range are rejected.
+var mappedOllamaOptions = map[string]bool{
+	"temperature": true, "top_p": true, "num_predict": true, "stop": true, "seed": true,
+	"presence_penalty": true, "frequency_penalty": true, "repeat_penalty": true, "top_k": true,
+}
+
+var ignoredOllamaOptions = map[string]bool{
+	"num_keep": true, "min_p": true, "typical_p": true, "tfs_z": true,
+	"repeat_last_n": true, "mirostat": true, "mirostat_tau": true, "mirostat_eta": true,
+	"penalize_newline": true, "numa": true, "num_ctx": true, "num_batch": true,
+	"num_gpu": true, "main_gpu": true, "low_vram": true, "f16_kv": true, "vocab_only": true,
+	"use_mmap": true, "use_mlock": true, "num_thread": true, "logits_all": true,
+}
+
+// translateOllamaOptions maps Ollama options onto OpenAI sampling parameters.
+// maxTokensField selects whether num_predict is sent as "max_tokens" or
+// "max_completion_tokens".
+func translateOllamaOptions(options map[string]interface{}, maxTokensField string) (models.OpenAISamplingParams, error) {
+	var params models.OpenAISamplingParams
+	var err error
+
+	if params.Temperature, err = floatOption(options, "temperature", 0, 2); err != nil {
+		return params, err
+	}
+	if params.TopP, err = floatOption(options, "top_p", 0, 1); err != nil {
+		return params, err
+	}
+	if params.PresencePenalty, err = floatOption(options, "presence_penalty", -2, 2); err != nil {
+		return params, err
+	}
+	if params.FrequencyPenalty, err = floatOption(options, "frequency_penalty", -2, 2); err != nil {
+		return params, err
+	}
+	if params.Seed, err = intOption(options, "seed"); err != nil {
+		return params, err
+	}
+	if params.TopK, err = intOption(options, "top_k"); err != nil {
+		return params, err
+	}
+	if params.Stop, err = stopOption(options); err != nil {
+		return params, err
+	}
+
+	numPredict, err := intOption(options, "num_predict")
+	if err != nil {
+		return params, err
+	}
+	if numPredict != nil {
+		switch {
+		case *numPredict > 0:
+			if maxTokensField == "max_completion_tokens" {
+				params.MaxCompletionTokens = numPredict
+			} else {
+				params.MaxTokens = numPredict
+			}
+		case *numPredict == -1 || *numPredict == -2:
+			// Unlimited generation is the OpenAI default
+		default:
+			return params, fmt.Errorf("option num_predict must be positive, -1 or -2, got %d", *numPredict)
+		}
+	}
+
+	repeatPenalty, err := floatOption(options, "repeat_penalty", 0, math.MaxFloat64)
+	if err != nil {
+		return params, err
+	}
+	if repeatPenalty != nil && params.FrequencyPenalty == nil {
+		penalty := math.Max(-2, math.Min(2, *repeatPenalty-1))
+		params.FrequencyPenalty = &penalty
+	}
+
+	var unknown []string
+	for key := range options {
+		if !mappedOllamaOptions[key] && !ignoredOllamaOptions[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		log.Printf("Ignoring unknown Ollama options: %v", unknown)
+	}
+
+	return params, nil
+}
+
+// floatOption reads a numeric option and checks it lies within [lo, hi].
+func floatOption(options map[string]interface{}, key string, lo, hi float64) (*float64, error) {
+	raw, ok := options[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	value, ok := raw.(float64)
+	if !ok {
+		return nil, fmt.Errorf("option %s must be a number, got %T", key, raw)
+	}
+	if value < lo || value > hi {
+		return nil, fmt.Errorf("option %s must be between %g and %g, got %g", key, lo, hi, value)
+	}
+	return &value, nil
+}
+
+// intOption reads an integer option.
+func intOption(options map[string]interface{}, key string) (*int, error) {
+	raw, ok := options[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	value, ok := raw.(float64)
+	if !ok || value != math.Trunc(value) {
+		return nil, fmt.Errorf("option %s must be an integer, got %v", key, raw)
+	}
+	intValue := int(value)
+	return &intValue, nil
+}
+
+// stopOption reads the stop option, given either as a string or an array of strings.
+func stopOption(options map[string]interface{}) ([]string, error) {
+	switch raw := options["stop"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{raw}, nil
+	case []interface{}:
+		stop := make([]string, 0, len(raw))
+		for _, item := range raw {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("option stop must only contain strings, got %T", item)
+			}
+			stop = append(stop, s)
+		}
+		return stop, nil
+	default:
+		return nil, fmt.Errorf("option stop must be a string or an array of strings, got %T", raw)
+	}
+}
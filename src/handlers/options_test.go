@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/models"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+func intPtr(v int) *int           { return &v }
+
+func TestTranslateOllamaOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		options        string
+		maxTokensField string
+		expected       models.OpenAISamplingParams
+	}{
+		{name: "no options", options: `{}`, expected: models.OpenAISamplingParams{}},
+		{name: "temperature", options: `{"temperature":0.7}`, expected: models.OpenAISamplingParams{Temperature: floatPtr(0.7)}},
+		{name: "top_p", options: `{"top_p":0.9}`, expected: models.OpenAISamplingParams{TopP: floatPtr(0.9)}},
+		{name: "num_predict to max_tokens", options: `{"num_predict":128}`, expected: models.OpenAISamplingParams{MaxTokens: intPtr(128)}},
+		{name: "num_predict to max_completion_tokens", options: `{"num_predict":128}`, maxTokensField: "max_completion_tokens", expected: models.OpenAISamplingParams{MaxCompletionTokens: intPtr(128)}},
+		{name: "num_predict infinite", options: `{"num_predict":-1}`, expected: models.OpenAISamplingParams{}},
+		{name: "num_predict fill context", options: `{"num_predict":-2}`, expected: models.OpenAISamplingParams{}},
+		{name: "stop string", options: `{"stop":"\n"}`, expected: models.OpenAISamplingParams{Stop: []string{"\n"}}},
+		{name: "stop array", options: `{"stop":["<|end|>","User:"]}`, expected: models.OpenAISamplingParams{Stop: []string{"<|end|>", "User:"}}},
+		{name: "seed", options: `{"seed":42}`, expected: models.OpenAISamplingParams{Seed: intPtr(42)}},
+		{name: "presence_penalty", options: `{"presence_penalty":0.5}`, expected: models.OpenAISamplingParams{PresencePenalty: floatPtr(0.5)}},
+		{name: "frequency_penalty", options: `{"frequency_penalty":-0.5}`, expected: models.OpenAISamplingParams{FrequencyPenalty: floatPtr(-0.5)}},
+		{name: "repeat_penalty approximation", options: `{"repeat_penalty":1.5}`, expected: models.OpenAISamplingParams{FrequencyPenalty: floatPtr(0.5)}},
+		{name: "repeat_penalty is clamped", options: `{"repeat_penalty":5}`, expected: models.OpenAISamplingParams{FrequencyPenalty: floatPtr(2)}},
+		{name: "frequency_penalty wins over repeat_penalty", options: `{"repeat_penalty":1.5,"frequency_penalty":0.1}`, expected: models.OpenAISamplingParams{FrequencyPenalty: floatPtr(0.1)}},
+		{name: "top_k is kept for backends that support it", options: `{"top_k":40}`, expected: models.OpenAISamplingParams{TopK: intPtr(40)}},
+		{name: "runtime options are ignored", options: `{"num_ctx":8192,"num_gpu":1,"mirostat":2}`, expected: models.OpenAISamplingParams{}},
+		{name: "unknown options are ignored", options: `{"made_up":true}`, expected: models.OpenAISamplingParams{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.options), &options); err != nil {
+				t.Fatalf("Invalid test options: %v", err)
+			}
+			params, err := translateOllamaOptions(options, tt.maxTokensField)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(params, tt.expected) {
+				gotJSON, _ := json.Marshal(params)
+				wantJSON, _ := json.Marshal(tt.expected)
+				t.Errorf("Unexpected params: got %s want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestTranslateOllamaOptions_Rejected(t *testing.T) {
+	tests := []struct {
+		options string
+		errText string
+	}{
+		{options: `{"temperature":"hot"}`, errText: "temperature must be a number"},
+		{options: `{"temperature":3}`, errText: "temperature must be between"},
+		{options: `{"top_p":1.5}`, errText: "top_p must be between"},
+		{options: `{"presence_penalty":-3}`, errText: "presence_penalty must be between"},
+		{options: `{"seed":1.5}`, errText: "seed must be an integer"},
+		{options: `{"num_predict":-5}`, errText: "num_predict must be positive"},
+		{options: `{"stop":[1,2]}`, errText: "stop must only contain strings"},
+		{options: `{"stop":7}`, errText: "stop must be a string or an array"},
+		{options: `{"repeat_penalty":-1}`, errText: "repeat_penalty must be between"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.options, func(t *testing.T) {
+			var options map[string]interface{}
+			json.Unmarshal([]byte(tt.options), &options)
+			if _, err := translateOllamaOptions(options, "max_tokens"); err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
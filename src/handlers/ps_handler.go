@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// PsHandler handles requests to /api/ps. Nothing is loaded behind an
+// upstream API, so the models with requests in flight and those used within
+// their keep-alive are listed instead, as Ollama lists loaded models.
+func PsHandler(w http.ResponseWriter, r *http.Request, cfg config.AppConfig, registry *backends.Registry) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	psResp := models.OllamaPsResponse{Models: []models.OllamaRunningModel{}}
+	for _, usage := range registry.Running() {
+		running := models.OllamaRunningModel{
+			Name:       usage.Name,
+			Model:      usage.Name,
+			ExpiresAt:  usage.ExpiresAt.Format(time.RFC3339),
+			InFlight:   usage.InFlight,
+			LastUsedAt: usage.LastUsed.Format(time.RFC3339),
+		}
+		names := []string{usage.Name}
+		if alias, ok := registry.Alias(usage.Name); ok {
+			running.Details.ParentModel = alias.Model
+			names = append(names, alias.Model)
+		}
+		if targets, err := registry.Targets(usage.Name); err == nil {
+			names = append(names, targets[0].Model)
+		}
+		metadata := findMetadata(cfg.Metadata, names...)
+		running.Details.Family = metadata.Family
+		if metadata.Family != "" {
+			running.Details.Families = []string{metadata.Family}
+		}
+		running.Details.ParameterSize = metadata.ParameterSize
+		running.Details.QuantizationLevel = metadata.QuantizationLevel
+		running.ContextLength = metadata.ContextLength
+		psResp.Models = append(psResp.Models, running)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(psResp); err != nil {
+		log.Printf("Error encoding ps response: %v", err)
+	}
+}
+
+// parseKeepAlive reads the keep_alive of a request, given as a duration such
+// as "5m" or in seconds. A negative keep_alive, or one too long for a
+// time.Duration, keeps the model listed for good. nil means the request didn't
+// set one.
+func parseKeepAlive(raw json.RawMessage) (*time.Duration, error) {
+	if len(raw) == 0 || string(raw) == "null" || string(raw) == `""` {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	var keepAlive time.Duration
+	switch value := value.(type) {
+	case float64:
+		// Converting values out of range would be undefined
+		if nanoseconds := value * float64(time.Second); nanoseconds < 0 || nanoseconds >= math.MaxInt64 {
+			keepAlive = math.MaxInt64
+		} else {
+			keepAlive = time.Duration(nanoseconds)
+		}
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		keepAlive = duration
+	default:
+		return nil, fmt.Errorf("expected a duration or a number of seconds, got %s", raw)
+	}
+	if keepAlive < 0 {
+		keepAlive = math.MaxInt64
+	}
+	return &keepAlive, nil
+}
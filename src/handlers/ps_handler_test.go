@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// ps lists the running models.
+func ps(t *testing.T, cfg config.AppConfig, registry *backends.Registry) models.OllamaPsResponse {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "/api/ps", nil)
+	rr := httptest.NewRecorder()
+	PsHandler(rr, req, cfg, registry)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	var resp models.OllamaPsResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	return resp
+}
+
+func TestPsHandler_ListsUsedModels(t *testing.T) {
+	release := make(chan struct{})
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var openAIReq models.OpenAIChatRequest
+		json.NewDecoder(r.Body).Decode(&openAIReq)
+		if strings.Contains(fmt.Sprint(openAIReq.Messages), "Reject") {
+			http.Error(w, `{"error":{"message":"The model does not exist"}}`, http.StatusNotFound)
+			return
+		}
+		// Accept the request, then stream the answer once released
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":"Hi"}}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer mockOpenAIServer.Close()
+	cfg := testConfig(mockOpenAIServer.URL)
+	cfg.Metadata = []config.MetadataConfig{{Models: []string{"gpt-4o"}, ContextLength: 128000, Family: "gpt"}}
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o"), reviewerAlias())
+
+	generate := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/generate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer testtoken")
+		rr := httptest.NewRecorder()
+		GenerateHandler(rr, req, cfg, registry)
+		return rr
+	}
+
+	// Rejected requests don't count
+	generate(`{"model":"reviewer","prompt":"Hi","options":{"temperature":"hot"}}`)
+	if rr := generate(`{"model":"reviewer","prompt":"Reject"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("Expected the upstream to reject the request, got %d", rr.Code)
+	}
+	if resp := ps(t, cfg, registry); len(resp.Models) != 0 {
+		t.Fatalf("Expected no models yet, got %+v", resp.Models)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		generate(`{"model":"reviewer","prompt":"Hi","keep_alive":"10m"}`)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	var resp models.OllamaPsResponse
+	for time.Now().Before(deadline) {
+		if resp = ps(t, cfg, registry); len(resp.Models) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(resp.Models) != 1 || resp.Models[0].Name != "reviewer:latest" || resp.Models[0].InFlight != 1 {
+		t.Fatalf("Expected the alias in flight, got %+v", resp.Models)
+	}
+	running := resp.Models[0]
+	if running.Details.ParentModel != "gpt-4o" || running.Details.Family != "gpt" || running.ContextLength != 128000 {
+		t.Errorf("Unexpected details: %+v", running)
+	}
+	close(release)
+	<-done
+
+	resp = ps(t, cfg, registry)
+	expiresAt, _ := time.Parse(time.RFC3339, resp.Models[0].ExpiresAt)
+	if resp.Models[0].InFlight != 0 || time.Until(expiresAt) < 9*time.Minute {
+		t.Errorf("Expected the model to be kept for 10m, got %+v", resp.Models[0])
+	}
+
+	// Unloading, as `ollama stop` does, removes it
+	rr := generate(`{"model":"reviewer","keep_alive":0}`)
+	if !strings.Contains(rr.Body.String(), `"done_reason":"unload"`) {
+		t.Errorf("Expected an unload answer, got %s", rr.Body.String())
+	}
+	if resp := ps(t, cfg, registry); len(resp.Models) != 0 {
+		t.Errorf("Expected no models after unloading, got %+v", resp.Models)
+	}
+}
+
+func TestParseKeepAlive(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want time.Duration
+	}{
+		{`"5m"`, 5 * time.Minute},
+		{`300`, 5 * time.Minute},
+		{`0`, 0},
+		{`"-1s"`, time.Duration(1<<63 - 1)},
+		{`-1`, time.Duration(1<<63 - 1)},
+		{`1e300`, time.Duration(1<<63 - 1)},
+		{`-1e300`, time.Duration(1<<63 - 1)},
+	}
+	for _, tt := range tests {
+		got, err := parseKeepAlive(json.RawMessage(tt.raw))
+		if err != nil || got == nil || *got != tt.want {
+			t.Errorf("parseKeepAlive(%s) = %v, %v; want %v", tt.raw, got, err, tt.want)
+		}
+	}
+	if got, err := parseKeepAlive(nil); got != nil || err != nil {
+		t.Errorf("Expected no keep-alive for an unset one, got %v, %v", got, err)
+	}
+	for _, raw := range []string{`"soon"`, `true`} {
+		if _, err := parseKeepAlive(json.RawMessage(raw)); err == nil {
+			t.Errorf("Expected an error for %s", raw)
+		}
+	}
+}
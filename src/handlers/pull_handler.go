@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// PullHandler handles requests to /api/pull. There is nothing to download, so
+// pulling checks that the model exists upstream, in the model list of the
+// backend serving it. A model no backend is configured to serve is looked up
+// in the lists of all backends and, if PROXY_PULL_MODE is enable, enabled on
+// the first backend listing it until the proxy restarts. Aliases and groups
+// are defined by the proxy and always succeed.
+func PullHandler(w http.ResponseWriter, r *http.Request, cfg config.AppConfig, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var pullReq models.OllamaPullRequest
+	if err := json.NewDecoder(r.Body).Decode(&pullReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	name := pullReq.Model
+	if name == "" {
+		name = pullReq.Name
+	}
+	if name == "" {
+		writeOllamaError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	clientAuth := r.Header.Get("Authorization")
+
+	progress := newProgressWriter(w, pullReq.Stream == nil || *pullReq.Stream)
+	progress.status("pulling manifest")
+	_, isAlias := registry.Alias(name)
+	targets, err := registry.Targets(name)
+	switch {
+	case isAlias || isGroup(registry, name):
+		if err != nil {
+			progress.fail(http.StatusNotFound, err.Error())
+			return
+		}
+	case err == nil:
+		target := targets[0]
+		result := fetchModels(r.Context(), target.Backend, clientAuth)
+		if result.status != http.StatusOK {
+			progress.fail(result.status, result.message)
+			return
+		}
+		if !listsModel(result.models, target.Model) {
+			progress.fail(http.StatusNotFound, fmt.Sprintf("model %q not found upstream on backend %s", target.Model, target.Backend.Name))
+			return
+		}
+	case cfg.PullMode != config.PullModeEnable:
+		progress.fail(http.StatusForbidden, fmt.Sprintf("model %q is not enabled on this proxy", name))
+		return
+	default:
+		backend, model, failure := findUpstreamModel(r, registry, name)
+		if failure != nil {
+			progress.fail(failure.status, failure.message)
+			return
+		}
+		progress.status("writing manifest")
+		backend.Enable(model)
+		log.Printf("Enabled model %s on backend %s", model, backend.Name)
+	}
+	progress.status("success")
+}
+
+// findUpstreamModel looks for name in the model lists of the backends, in
+// configuration order. A name written as backend/model is looked up on the
+// named backend first. When no backend lists the model, the failure is not
+// found, or the first error if no list could be fetched.
+func findUpstreamModel(r *http.Request, registry *backends.Registry, name string) (*backends.Backend, string, *modelsResult) {
+	type candidate struct {
+		backend *backends.Backend
+		model   string
+	}
+	var candidates []candidate
+	if prefix, model, found := strings.Cut(name, "/"); found {
+		for _, backend := range registry.Backends() {
+			if backend.Name == prefix {
+				candidates = append(candidates, candidate{backend, model})
+			}
+		}
+	}
+	for _, backend := range registry.Backends() {
+		candidates = append(candidates, candidate{backend, name})
+	}
+
+	var firstFailure *modelsResult
+	succeeded := false
+	fetched := make(map[*backends.Backend]modelsResult)
+	for _, c := range candidates {
+		result, ok := fetched[c.backend]
+		if !ok {
+			result = fetchModels(r.Context(), c.backend, r.Header.Get("Authorization"))
+			fetched[c.backend] = result
+		}
+		if result.status != http.StatusOK {
+			if firstFailure == nil {
+				firstFailure = &result
+			}
+			continue
+		}
+		succeeded = true
+		if listsModel(result.models, c.model) {
+			return c.backend, c.model, nil
+		}
+	}
+	if !succeeded && firstFailure != nil {
+		return nil, "", firstFailure
+	}
+	return nil, "", &modelsResult{status: http.StatusNotFound, message: fmt.Sprintf("model %q not found upstream", name)}
+}
+
+// listsModel reports whether model is in list.
+func listsModel(list []models.OpenAIModel, model string) bool {
+	for _, listed := range list {
+		if listed.ID == model {
+			return true
+		}
+	}
+	return false
+}
+
+// isGroup reports whether name is a model group.
+func isGroup(registry *backends.Registry, name string) bool {
+	for _, group := range registry.Groups() {
+		if group.Name == name {
+			return true
+		}
+	}
+	return false
+}
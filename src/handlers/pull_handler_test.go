@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// pullRegistry serves gpt-4o from a backend listing gpt-4o, gpt-4o-mini and
+// o3, with the alias reviewer for gpt-4o.
+func pullRegistry(t *testing.T) (*backends.Registry, func()) {
+	t.Helper()
+	mockOpenAIServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := models.OpenAIModelsResponse{Object: "list"}
+		for _, id := range []string{"gpt-4o", "gpt-4o-mini", "o3"} {
+			response.Data = append(response.Data, models.OpenAIModel{ID: id, Object: "model", Created: 1700000000})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	registry := testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4o", "gpt-5"), reviewerAlias())
+	return registry, mockOpenAIServer.Close
+}
+
+// pull sends a /api/pull request and returns the status code and the
+// streamed statuses, or the error.
+func pull(t *testing.T, cfg config.AppConfig, registry *backends.Registry, model string, stream bool) (int, []string) {
+	t.Helper()
+	return create(t, func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/api/pull"
+		r.Header.Set("Authorization", "Bearer testtoken")
+		PullHandler(w, r, cfg, registry)
+	}, fmt.Sprintf(`{"model":%q,"stream":%t}`, model, stream))
+}
+
+func TestPullHandler_ServedModels(t *testing.T) {
+	registry, closeServer := pullRegistry(t)
+	defer closeServer()
+	cfg := testConfig("")
+	cfg.PullMode = config.PullModeEnable
+
+	code, statuses := pull(t, cfg, registry, "gpt-4o", true)
+	if code != http.StatusOK || strings.Join(statuses, "|") != "pulling manifest|success" {
+		t.Errorf("Expected gpt-4o to be pulled, got %d %v", code, statuses)
+	}
+	if code, _ := pull(t, cfg, registry, "reviewer", false); code != http.StatusOK {
+		t.Errorf("Expected aliases to be pulled, got %d", code)
+	}
+	// Configured, but not available upstream
+	if code, statuses := pull(t, cfg, registry, "gpt-5", false); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a model the backend doesn't list, got %d %v", code, statuses)
+	}
+	code, statuses = pull(t, cfg, registry, "gpt-5", true)
+	if code != http.StatusOK || len(statuses) != 2 || !strings.Contains(statuses[1], "not found") {
+		t.Errorf("Expected the stream to end with an error, got %d %v", code, statuses)
+	}
+}
+
+func TestPullHandler_EnablesModels(t *testing.T) {
+	registry, closeServer := pullRegistry(t)
+	defer closeServer()
+	cfg := testConfig("")
+
+	cfg.PullMode = config.PullModeVerify
+	if code, _ := pull(t, cfg, registry, "gpt-4o-mini", false); code != http.StatusForbidden {
+		t.Errorf("Expected 403 when enabling is turned off, got %d", code)
+	}
+
+	cfg.PullMode = config.PullModeEnable
+	code, statuses := pull(t, cfg, registry, "gpt-4o-mini", true)
+	if code != http.StatusOK || strings.Join(statuses, "|") != "pulling manifest|writing manifest|success" {
+		t.Fatalf("Expected gpt-4o-mini to be enabled, got %d %v", code, statuses)
+	}
+	if code, _ := pull(t, cfg, registry, "openai/o3", false); code != http.StatusOK {
+		t.Fatalf("Expected openai/o3 to be enabled, got %d", code)
+	}
+	if code, _ := pull(t, cfg, registry, "claude-3", false); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a model no backend lists, got %d", code)
+	}
+	for _, model := range []string{"gpt-4o-mini", "o3"} {
+		if targets, err := registry.Resolve(model); err != nil || targets[0].Model != model {
+			t.Errorf("Expected %s to be served now, got %v, %v", model, targets, err)
+		}
+	}
+
+	// Enabled models are listed
+	req, _ := http.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Authorization", "Bearer testtoken")
+	rr := httptest.NewRecorder()
+	GetModelsHandler(rr, req, registry)
+	var tags models.OllamaTagsResponse
+	json.NewDecoder(rr.Body).Decode(&tags)
+	var names []string
+	for _, model := range tags.Models {
+		names = append(names, model.Name)
+	}
+	if got := strings.Join(names, ","); got != "gpt-4o,gpt-4o-mini,o3,reviewer:latest" {
+		t.Errorf("Unexpected models: %s", got)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/models"
+)
+
+// defaultTemplate is the template Ollama reports for models without one.
+const defaultTemplate = "{{ .Prompt }}"
+
+// ShowHandler handles requests to /api/show. Upstream APIs only list model
+// names, so the answer is put together from the alias definition of the model,
+// the metadata configured for it and defaults: chat models complete, call
+// tools and see images unless they are in TEXT_ONLY_MODELS, models in
+// REASONING_MODELS think, and models with "embed" in their name only embed.
+func ShowHandler(w http.ResponseWriter, r *http.Request, cfg config.AppConfig, registry *backends.Registry) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var showReq models.OllamaShowRequest
+	if err := json.NewDecoder(r.Body).Decode(&showReq); err != nil {
+		http.Error(w, "Bad request: Could not decode JSON", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	name := showReq.Model
+	if name == "" {
+		name = showReq.Name
+	}
+	if name == "" {
+		writeOllamaError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	targets, err := registry.Targets(name)
+	if err != nil {
+		writeOllamaError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	upstreamModel := targets[0].Model
+
+	definition := config.AliasConfig{Name: name, Model: name}
+	showResp := models.OllamaShowResponse{}
+	if alias, ok := registry.Alias(name); ok {
+		definition = alias.Config()
+		showResp.Details.ParentModel = alias.Model
+		showResp.ModifiedAt = alias.Created.UTC().Format(time.RFC3339)
+	}
+	metadata := findMetadata(cfg.Metadata, name, definition.Model, upstreamModel)
+
+	showResp.Modelfile = renderModelfile(name, definition)
+	showResp.Parameters = renderParameters(definition.Options)
+	showResp.Template = definition.Template
+	if showResp.Template == "" {
+		showResp.Template = defaultTemplate
+	}
+	showResp.System = definition.System
+	for _, msg := range definition.Messages {
+		showResp.Messages = append(showResp.Messages, models.OllamaChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	showResp.Details.Family = metadata.Family
+	if metadata.Family != "" {
+		showResp.Details.Families = []string{metadata.Family}
+	}
+	showResp.Details.ParameterSize = metadata.ParameterSize
+	showResp.Details.QuantizationLevel = metadata.QuantizationLevel
+
+	// Clients look up lengths under the architecture, e.g. llama.context_length
+	architecture := metadata.Family
+	if architecture == "" {
+		architecture = targets[0].Backend.Type
+	}
+	if architecture == "" {
+		architecture = config.BackendTypeOpenAI
+	}
+	showResp.ModelInfo = map[string]interface{}{
+		"general.architecture": architecture,
+		"general.basename":     upstreamModel,
+	}
+	if metadata.ContextLength > 0 {
+		showResp.ModelInfo[architecture+".context_length"] = metadata.ContextLength
+	}
+	if metadata.EmbeddingLength > 0 {
+		showResp.ModelInfo[architecture+".embedding_length"] = metadata.EmbeddingLength
+	}
+	showResp.Capabilities = modelCapabilities(cfg, metadata, upstreamModel)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(showResp); err != nil {
+		log.Printf("Error encoding show response: %v", err)
+	}
+}
+
+// findMetadata returns the first metadata entry matching one of the names.
+func findMetadata(catalog []config.MetadataConfig, names ...string) config.MetadataConfig {
+	for _, metadata := range catalog {
+		for _, name := range names {
+			if matchesModel(name, metadata.Models) {
+				return metadata
+			}
+		}
+	}
+	return config.MetadataConfig{}
+}
+
+// modelCapabilities returns the configured capabilities of model, or the ones
+// the proxy's settings imply.
+func modelCapabilities(cfg config.AppConfig, metadata config.MetadataConfig, model string) []string {
+	if len(metadata.Capabilities) > 0 {
+		return metadata.Capabilities
+	}
+	if strings.Contains(strings.ToLower(model), "embed") {
+		return []string{config.CapabilityEmbedding}
+	}
+	capabilities := []string{config.CapabilityCompletion, config.CapabilityTools}
+	if !matchesModel(model, cfg.TextOnlyModels) {
+		capabilities = append(capabilities, config.CapabilityVision)
+	}
+	if matchesModel(model, cfg.ReasoningModels) {
+		capabilities = append(capabilities, config.CapabilityThinking)
+	}
+	return capabilities
+}
+
+// renderModelfile writes the definition of a model as a Modelfile that
+// /api/create accepts.
+func renderModelfile(name string, definition config.AliasConfig) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Modelfile generated by \"ollama show\"\n# To build a new Modelfile based on this, replace FROM with:\n# FROM %s\n\n", name)
+	fmt.Fprintf(&b, "FROM %s\n", definition.Model)
+	if definition.Template != "" {
+		fmt.Fprintf(&b, "TEMPLATE \"\"\"%s\"\"\"\n", definition.Template)
+	}
+	if definition.System != "" {
+		fmt.Fprintf(&b, "SYSTEM \"\"\"%s\"\"\"\n", definition.System)
+	}
+	for _, key := range sortedKeys(definition.Options) {
+		for _, value := range parameterValues(definition.Options[key]) {
+			fmt.Fprintf(&b, "PARAMETER %s %s\n", key, value)
+		}
+	}
+	for _, msg := range definition.Messages {
+		content := msg.Content
+		if strings.Contains(content, "\n") || strings.HasPrefix(content, `"`) {
+			content = `"""` + content + `"""`
+		}
+		fmt.Fprintf(&b, "MESSAGE %s %s\n", msg.Role, content)
+	}
+	return b.String()
+}
+
+// renderParameters lists options the way Ollama shows parameters, one value
+// per line.
+func renderParameters(options map[string]interface{}) string {
+	var b strings.Builder
+	for _, key := range sortedKeys(options) {
+		for _, value := range parameterValues(options[key]) {
+			fmt.Fprintf(&b, "%-30s %s\n", key, value)
+		}
+	}
+	return b.String()
+}
+
+// parameterValues formats an option value as Modelfile parameters. A list,
+// such as stop, gives one parameter per item.
+func parameterValues(value interface{}) []string {
+	switch value := value.(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			values = append(values, parameterValues(item)...)
+		}
+		return values
+	case string:
+		return []string{`"` + value + `"`}
+	case float64:
+		return []string{strconv.FormatFloat(value, 'f', -1, 64)}
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+func sortedKeys(options map[string]interface{}) []string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
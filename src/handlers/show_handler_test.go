@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/modelfile"
+	"ollama-openai-proxy/src/models"
+)
+
+// show sends a /api/show request for model.
+func show(t *testing.T, cfg config.AppConfig, registry *backends.Registry, model string) (int, models.OllamaShowResponse) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", "/api/show", strings.NewReader(`{"model":"`+model+`"}`))
+	rr := httptest.NewRecorder()
+	ShowHandler(rr, req, cfg, registry)
+	var resp models.OllamaShowResponse
+	json.NewDecoder(rr.Body).Decode(&resp)
+	return rr.Code, resp
+}
+
+func TestShowHandler_Alias(t *testing.T) {
+	cfg := testConfig("http://dummyurl")
+	cfg.Metadata = []config.MetadataConfig{
+		{Models: []string{"gpt-4o*"}, ContextLength: 128000, Family: "gpt", Capabilities: []string{"completion", "tools", "vision"}},
+	}
+	registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"), reviewerAlias())
+	registry.CreateAlias(config.AliasConfig{
+		Name: "helper", Model: "gpt-4o", System: "Be brief.",
+		Options:  map[string]interface{}{"temperature": 0.2, "stop": []interface{}{"END", "STOP"}},
+		Messages: []config.AliasMessage{{Role: "user", Content: "Hi"}},
+	})
+
+	code, resp := show(t, cfg, registry, "helper")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if resp.System != "Be brief." || resp.Template != defaultTemplate || resp.Details.ParentModel != "gpt-4o" || resp.Details.Family != "gpt" || len(resp.Messages) != 1 {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if want := "stop                           \"END\"\nstop                           \"STOP\"\ntemperature                    0.2\n"; resp.Parameters != want {
+		t.Errorf("Got parameters %q, want %q", resp.Parameters, want)
+	}
+	if resp.ModelInfo["gpt.context_length"] != float64(128000) || resp.ModelInfo["general.architecture"] != "gpt" {
+		t.Errorf("Unexpected model info: %+v", resp.ModelInfo)
+	}
+	if want := []string{"completion", "tools", "vision"}; !reflect.DeepEqual(resp.Capabilities, want) {
+		t.Errorf("Got capabilities %v, want %v", resp.Capabilities, want)
+	}
+
+	// The Modelfile recreates the alias
+	mf, err := modelfile.Parse(resp.Modelfile)
+	if err != nil {
+		t.Fatalf("Modelfile %q does not parse: %v", resp.Modelfile, err)
+	}
+	if mf.From != "gpt-4o" || mf.System != "Be brief." || mf.Parameters["temperature"] != 0.2 ||
+		!reflect.DeepEqual(mf.Parameters["stop"], []interface{}{"END", "STOP"}) || len(mf.Messages) != 1 {
+		t.Errorf("Unexpected Modelfile %q", resp.Modelfile)
+	}
+}
+
+func TestShowHandler_DefaultCapabilities(t *testing.T) {
+	cfg := testConfig("http://dummyurl")
+	cfg.TextOnlyModels = []string{"gpt-3.5-*"}
+	cfg.ReasoningModels = []string{"o3*"}
+	registry := testRegistry(t, openAIBackend("http://dummyurl"))
+
+	tests := map[string][]string{
+		"gpt-4o":                 {"completion", "tools", "vision"},
+		"gpt-3.5-turbo":          {"completion", "tools"},
+		"o3-mini":                {"completion", "tools", "vision", "thinking"},
+		"text-embedding-3-small": {"embedding"},
+	}
+	for model, want := range tests {
+		code, resp := show(t, cfg, registry, model)
+		if code != http.StatusOK || !reflect.DeepEqual(resp.Capabilities, want) {
+			t.Errorf("%s: got %d %v, want %v", model, code, resp.Capabilities, want)
+		}
+		if resp.ModelInfo["general.architecture"] != "openai" || resp.Modelfile == "" || resp.Details.ParentModel != "" {
+			t.Errorf("%s: unexpected response %+v", model, resp)
+		}
+	}
+}
+
+func TestShowHandler_UnknownModel(t *testing.T) {
+	registry := testRegistry(t, openAIBackend("http://dummyurl", "gpt-4o"))
+	if code, _ := show(t, testConfig("http://dummyurl"), registry, "claude-3"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown model, got %d", code)
+	}
+}
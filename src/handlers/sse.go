@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// sseEvent is a single dispatched Server-Sent Event.
+type sseEvent struct {
+	Event string
+	Data  string
+	ID    string
+}
+
+// sseReader incrementally parses a text/event-stream body.
+// It follows the WHATWG event stream rules: consecutive `data:` lines are
+// joined with "\n", lines starting with ':' are comments (keepalives) and a
+// blank line dispatches the event.
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	// bufio.Reader instead of bufio.Scanner: a single data line can easily
+	// exceed the Scanner's 64KB token limit (e.g. large tool call arguments).
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+// Next blocks until the next event is available. It returns io.EOF once the
+// stream has ended and no buffered event is left.
+func (s *sseReader) Next() (sseEvent, error) {
+	var ev sseEvent
+	var data []string
+	hasData := false
+
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return sseEvent{}, err
+		}
+		if err == io.EOF && line == "" {
+			if hasData { // Stream ended without a trailing blank line
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			return sseEvent{}, io.EOF
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+
+		if line == "" {
+			if hasData {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			// Events without data are not dispatched
+			ev = sseEvent{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment, usually a keepalive
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+			hasData = true
+		case "event":
+			ev.Event = value
+		case "id":
+			ev.ID = value
+		default:
+			// "retry" and unknown fields are ignored
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSSEReader_Next(t *testing.T) {
+	stream := ": comment\n\n" +
+		"event: update\nid: 7\ndata: line1\ndata:line2\n\n" +
+		"retry: 1000\n\n" +
+		"data: tail"
+
+	reader := newSSEReader(strings.NewReader(stream))
+
+	event, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event.Event != "update" || event.ID != "7" || event.Data != "line1\nline2" {
+		t.Errorf("Unexpected first event: %+v", event)
+	}
+
+	event, err = reader.Next()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if event.Data != "tail" || event.Event != "" {
+		t.Errorf("Unexpected trailing event: %+v", event)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
@@ -1,93 +1,41 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
 
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
 	"ollama-openai-proxy/src/models"
 	"time"
 )
 
 // GetModelsHandler handles requests to /api/tags.
-// Signature changed to accept config values.
-func GetModelsHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL string, allowedModelsList []string) {
+func GetModelsHandler(w http.ResponseWriter, r *http.Request, registry *backends.Registry) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// openAIBaseURL and allowedModelsList are now passed as arguments
-	// Default values are handled by config.LoadConfig()
-
-	authToken := r.Header.Get("Authorization")
-	if authToken == "" {
-		http.Error(w, "Unauthorized: Missing Authorization header", http.StatusUnauthorized)
-		return
-	}
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", openAIBaseURL+"/v1/models", nil) // Use passed-in openAIBaseURL
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		http.Error(w, "Failed to create request to OpenAI", http.StatusInternalServerError)
-		return
-	}
-	req.Header.Set("Authorization", authToken)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Error fetching models from OpenAI: %v", err)
-		http.Error(w, "Failed to fetch models from OpenAI", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("OpenAI API error: %s", resp.Status)
-		// TODO: It might be useful to relay more specific error information if possible
-		http.Error(w, "Failed to fetch models from OpenAI: "+resp.Status, resp.StatusCode)
-		return
-	}
-
-	var openAIResp models.OpenAIModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openAIResp); err != nil {
-		log.Printf("Error decoding OpenAI response: %v", err)
-		http.Error(w, "Failed to decode response from OpenAI", http.StatusInternalServerError)
+	listed, failure := listModels(r, registry)
+	if failure != nil {
+		http.Error(w, failure.message, failure.status)
 		return
 	}
 
-	var filteredOpenAIModels []models.OpenAIModel
-	if len(allowedModelsList) > 0 { // Use passed-in allowedModelsList
-		allowedMap := make(map[string]bool)
-		for _, modelID := range allowedModelsList {
-			// Assuming model IDs are already trimmed by LoadConfig
-			allowedMap[modelID] = true
-		}
-		for _, model := range openAIResp.Data {
-			if _, ok := allowedMap[model.ID]; ok {
-				filteredOpenAIModels = append(filteredOpenAIModels, model)
-			}
-		}
-	} else {
-		filteredOpenAIModels = openAIResp.Data
-	}
-
-	ollamaModels := make([]models.OllamaModel, len(filteredOpenAIModels))
-	for i, openAIModel := range filteredOpenAIModels {
-		name := openAIModel.Name
-		if len(name) == 0 {
-			name = openAIModel.ID
-		}
-
+	ollamaModels := make([]models.OllamaModel, len(listed))
+	for i, model := range listed {
 		ollamaModels[i] = models.OllamaModel{
-			Name:       name,
-			Model:      openAIModel.ID,
-			ModifiedAt: time.Unix(openAIModel.Created, 0).UTC().Format(time.RFC3339),
+			Name:       model.name,
+			Model:      model.id,
+			ModifiedAt: time.Unix(model.created, 0).UTC().Format(time.RFC3339),
 			Size:       0,  // Not available from OpenAI
 			Digest:     "", // Not available from OpenAI
 			Details: models.OllamaModelDetails{ // Populate with defaults or leave empty
-				ParentModel:       "",
+				ParentModel:       model.parent,
 				Format:            "",
 				Family:            "",
 				Families:          nil,
@@ -103,3 +51,181 @@ func GetModelsHandler(w http.ResponseWriter, r *http.Request, openAIBaseURL stri
 		log.Printf("Error encoding Ollama response: %v", err)
 	}
 }
+
+// listedModel is a model offered by the proxy. id is the name to request it
+// by, name the name to display. parent is the model an alias stands for.
+type listedModel struct {
+	name, id string
+	created  int64
+	ownedBy  string
+	parent   string
+}
+
+// listModels fetches the model lists of all backends concurrently and merges
+// them. Each backend only contributes the models it is configured to serve,
+// followed by the model groups with at least one listed member and the
+// aliases of listed models. A backend that
+// fails is left out; only when all of them fail is the first failure returned.
+func listModels(r *http.Request, registry *backends.Registry) ([]listedModel, *modelsResult) {
+	backendList := registry.Backends()
+	results := make([]modelsResult, len(backendList))
+	var wg sync.WaitGroup
+	for i, backend := range backendList {
+		wg.Add(1)
+		go func(i int, backend *backends.Backend) {
+			defer wg.Done()
+			results[i] = fetchModels(r.Context(), backend, r.Header.Get("Authorization"))
+		}(i, backend)
+	}
+	wg.Wait()
+
+	listed := []listedModel{}
+	seen := make(map[string]bool)
+	var firstFailure *modelsResult
+	succeeded := false
+	for i, backend := range backendList {
+		result := &results[i]
+		if result.status != http.StatusOK {
+			log.Printf("Skipping models of backend %s: %s", backend.Name, result.message)
+			if firstFailure == nil {
+				firstFailure = result
+			}
+			continue
+		}
+		succeeded = true
+
+		for _, openAIModel := range result.models {
+			if !backend.Serves(openAIModel.ID) {
+				continue
+			}
+			name := openAIModel.Name
+			if len(name) == 0 {
+				name = openAIModel.ID
+			}
+			modelID := openAIModel.ID
+			if registry.PrefixModels() {
+				name = backend.Name + "/" + name
+				modelID = backend.Name + "/" + modelID
+			}
+			if seen[modelID] {
+				continue // Requests for this name go to the first backend anyway
+			}
+			seen[modelID] = true
+			listed = append(listed, listedModel{name: name, id: modelID, created: openAIModel.Created, ownedBy: backend.Name})
+		}
+	}
+	// Groups are listed when one of their members is available
+	for _, group := range registry.Groups() {
+		if seen[group.Name] {
+			continue
+		}
+		if created, ok := targetsCreated(group.Members, backendList, results); ok {
+			seen[group.Name] = true
+			listed = append(listed, listedModel{name: group.Name, id: group.Name, created: created, ownedBy: "group"})
+		}
+	}
+	// And so are aliases
+	for _, alias := range registry.Aliases() {
+		if seen[alias.Name] {
+			continue
+		}
+		targets, err := registry.Targets(alias.Name)
+		if err != nil {
+			continue
+		}
+		if _, ok := targetsCreated(targets, backendList, results); ok {
+			seen[alias.Name] = true
+			listed = append(listed, listedModel{
+				name:    alias.Name,
+				id:      alias.Name,
+				created: alias.Created.Unix(),
+				ownedBy: "alias",
+				parent:  alias.Model,
+			})
+		}
+	}
+	if !succeeded && firstFailure != nil {
+		return nil, firstFailure
+	}
+	return listed, nil
+}
+
+// targetsCreated finds the first of targets its backend listed and returns the
+// target model's creation time.
+func targetsCreated(targets []backends.Target, backendList []*backends.Backend, results []modelsResult) (int64, bool) {
+	for _, member := range targets {
+		for i, backend := range backendList {
+			if backend != member.Backend || results[i].status != http.StatusOK {
+				continue
+			}
+			for _, openAIModel := range results[i].models {
+				if openAIModel.ID == member.Model {
+					return openAIModel.Created, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// modelsResult is the outcome of fetching the models of one backend. On
+// failure status and message describe the error for the client.
+type modelsResult struct {
+	models  []models.OpenAIModel
+	status  int
+	message string
+}
+
+// fetchModels requests /v1/models from backend. The models of Azure OpenAI
+// backends come from their configuration.
+func fetchModels(ctx context.Context, backend *backends.Backend, clientAuth string) modelsResult {
+	if backend.Type == config.BackendTypeAzure {
+		return modelsResult{models: azureModels(backend), status: http.StatusOK}
+	}
+	authToken := backend.Authorization(clientAuth)
+	if authToken == "" {
+		return modelsResult{status: http.StatusUnauthorized, message: "Unauthorized: Missing Authorization header"}
+	}
+
+	modelsURL := backend.BaseURL + "/v1/models"
+	switch backend.Type {
+	case config.BackendTypeAnthropic:
+		modelsURL += "?limit=1000" // Anthropic pages the list, 20 models by default
+	case config.BackendTypeGemini:
+		modelsURL = backend.BaseURL + geminiAPIPath + "/models?pageSize=1000"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", modelsURL, nil)
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		return modelsResult{status: http.StatusInternalServerError, message: "Failed to create request to OpenAI"}
+	}
+	setAuthHeaders(req, backend, authToken)
+
+	resp, err := backend.Client.Do(req, false)
+	if err != nil {
+		log.Printf("Error fetching models from %s: %v", backend.Name, err)
+		return modelsResult{status: http.StatusInternalServerError, message: "Failed to fetch models from OpenAI"}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("OpenAI API error from %s: %s", backend.Name, resp.Status)
+		// TODO: It might be useful to relay more specific error information if possible
+		return modelsResult{status: resp.StatusCode, message: "Failed to fetch models from OpenAI: " + resp.Status}
+	}
+
+	var openAIResp models.OpenAIModelsResponse
+	switch backend.Type {
+	case config.BackendTypeAnthropic:
+		openAIResp.Data, err = decodeAnthropicModels(resp.Body)
+	case config.BackendTypeGemini:
+		openAIResp.Data, err = decodeGeminiModels(resp.Body)
+	default:
+		err = json.NewDecoder(resp.Body).Decode(&openAIResp)
+	}
+	if err != nil {
+		log.Printf("Error decoding OpenAI response from %s: %v", backend.Name, err)
+		return modelsResult{status: http.StatusInternalServerError, message: "Failed to decode response from OpenAI"}
+	}
+	return modelsResult{models: openAIResp.Data, status: http.StatusOK}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"ollama-openai-proxy/src/backends"
+	"ollama-openai-proxy/src/config"
 	"ollama-openai-proxy/src/models" // Assuming module name is ollama-openai-proxy
 	"reflect"
 	"strings"
@@ -53,7 +55,7 @@ func TestGetModelsHandler_Success_NoFilter(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	// Call GetModelsHandler with mock server's URL and no filter
-	GetModelsHandler(rr, req, mockOpenAIServer.URL, []string{})
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("Handler returned wrong status code: got %v want %v. Body: %s", status, http.StatusOK, rr.Body.String())
@@ -104,7 +106,7 @@ func TestGetModelsHandler_Success_WithFilter(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	// Filter for "gpt-4" and "dall-e-3"
-	GetModelsHandler(rr, req, mockOpenAIServer.URL, []string{"gpt-4", "dall-e-3"})
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL, "gpt-4", "dall-e-3")))
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("Handler returned wrong status code: got %v want %v. Body: %s", status, http.StatusOK, rr.Body.String())
@@ -140,7 +142,7 @@ func TestGetModelsHandler_OpenAIError(t *testing.T) {
 	req.Header.Set("Authorization", "Bearer testtoken")
 
 	rr := httptest.NewRecorder()
-	GetModelsHandler(rr, req, mockOpenAIServer.URL, []string{})
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
 	// The handler forwards OpenAI's status code
 	if status := rr.Code; status != http.StatusInternalServerError {
@@ -174,7 +176,7 @@ func TestGetModelsHandler_MissingAuthHeader(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 	// The openAIBaseURL and allowedModelsList don't matter as auth should fail first
-	GetModelsHandler(rr, req, "http://dummyurl", []string{})
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl")))
 
 	if status := rr.Code; status != http.StatusUnauthorized {
 		t.Errorf("Handler returned wrong status code for missing auth: got %v want %v. Body: %s", status, http.StatusUnauthorized, rr.Body.String())
@@ -193,7 +195,7 @@ func TestGetModelsHandler_MethodNotAllowed(t *testing.T) {
 	req.Header.Set("Authorization", "Bearer testtoken")
 
 	rr := httptest.NewRecorder()
-	GetModelsHandler(rr, req, "http://dummyurl", []string{})
+	GetModelsHandler(rr, req, testRegistry(t, openAIBackend("http://dummyurl")))
 
 	if status := rr.Code; status != http.StatusMethodNotAllowed {
 		t.Errorf("Handler returned wrong status code for wrong method: got %v want %v. Body: %s", status, http.StatusMethodNotAllowed, rr.Body.String())
@@ -227,7 +229,7 @@ func TestGetModelsHandler_EmptyOpenAIResponse(t *testing.T) {
     req.Header.Set("Authorization", "Bearer testtoken")
 
     rr := httptest.NewRecorder()
-    GetModelsHandler(rr, req, mockOpenAIServer.URL, []string{})
+    GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
     if status := rr.Code; status != http.StatusOK {
         t.Errorf("Handler returned wrong status code: got %v want %v. Body: %s", status, http.StatusOK, rr.Body.String())
@@ -257,7 +259,7 @@ func TestGetModelsHandler_OpenAIUnmarshallingError(t *testing.T) {
     req.Header.Set("Authorization", "Bearer testtoken")
 
     rr := httptest.NewRecorder()
-    GetModelsHandler(rr, req, mockOpenAIServer.URL, []string{})
+    GetModelsHandler(rr, req, testRegistry(t, openAIBackend(mockOpenAIServer.URL)))
 
     if status := rr.Code; status != http.StatusInternalServerError {
         t.Errorf("Handler returned wrong status code for OpenAI unmarshal error: got %v want %v. Body: %s", status, http.StatusInternalServerError, rr.Body.String())
@@ -267,3 +269,59 @@ func TestGetModelsHandler_OpenAIUnmarshallingError(t *testing.T) {
         t.Errorf("Handler returned unexpected error body: got '%s', expected to contain '%s'", rr.Body.String(), expectedErrorSubstring)
     }
 }
+
+func TestGetModelsHandler_MergesBackends(t *testing.T) {
+	mockBackend := func(ids ...string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			response := models.OpenAIModelsResponse{Object: "list"}
+			for _, id := range ids {
+				response.Data = append(response.Data, models.OpenAIModel{ID: id, Object: "model", Created: 1700000000})
+			}
+			json.NewEncoder(w).Encode(response)
+		}))
+	}
+	local := mockBackend("llama3", "qwen3")
+	defer local.Close()
+	cloud := mockBackend("gpt-4o", "llama3")
+	defer cloud.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	tags := func(prefixModels bool) []string {
+		registry, err := backends.NewRegistry(config.AppConfig{
+			PrefixModels: prefixModels,
+			Backends: []config.BackendConfig{
+				{Name: "local", BaseURL: local.URL, Models: []string{"llama*", "qwen*"}},
+				{Name: "broken", BaseURL: broken.URL},
+				{Name: "cloud", BaseURL: cloud.URL, Models: []string{"gpt-*"}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest("GET", "/api/tags", nil)
+		req.Header.Set("Authorization", "Bearer testtoken")
+		rr := httptest.NewRecorder()
+		GetModelsHandler(rr, req, registry)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected the failing backend to be skipped, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp models.OllamaTagsResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		var names []string
+		for _, model := range resp.Models {
+			names = append(names, model.Name)
+		}
+		return names
+	}
+
+	// The cloud backend's llama3 is not one of its models
+	if got, want := tags(false), []string{"llama3", "qwen3", "gpt-4o"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got models %v, want %v", got, want)
+	}
+	if got, want := tags(true), []string{"local/llama3", "local/qwen3", "cloud/gpt-4o"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got prefixed models %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"ollama-openai-proxy/src/models"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// parseThink reads Ollama's `think` field, which is either a boolean or a
+// reasoning level. effort is empty when the upstream default should be used.
+func parseThink(raw json.RawMessage) (enabled bool, effort string, err error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false, "", nil
+	}
+	if json.Unmarshal(raw, &enabled) == nil {
+		return enabled, "", nil
+	}
+	var level string
+	if json.Unmarshal(raw, &level) == nil {
+		switch level {
+		case "low", "medium", "high":
+			return true, level, nil
+		}
+		return false, "", fmt.Errorf("think must be true, false, \"low\", \"medium\" or \"high\", got %q", level)
+	}
+	return false, "", fmt.Errorf("think must be a boolean or a string, got %s", raw)
+}
+
+// reasoningEffort returns the reasoning_effort to send for model, if any.
+// Only models listed in reasoningModels accept the parameter.
+func reasoningEffort(model string, think bool, effort string, reasoningModels []string) string {
+	if !think || !matchesModel(model, reasoningModels) {
+		return ""
+	}
+	if effort == "" {
+		return "medium"
+	}
+	return effort
+}
+
+// reasoningText returns the reasoning of a message or delta, whichever field
+// the provider uses for it.
+func reasoningText(message models.OpenAIChatMessage) string {
+	if message.ReasoningContent != "" {
+		return message.ReasoningContent
+	}
+	return message.Reasoning
+}
+
+// thinkingSplitter separates reasoning from the answer of a chat response.
+// Reasoning arrives either in a dedicated field (reasoning_content or
+// reasoning) or inline as <think>...</think> blocks in the content. Reasoning
+// is only returned when the client enabled thinking, as Ollama does.
+type thinkingSplitter struct {
+	think    bool
+	strategy string // config.AppConfig.ThinkTagStrategy
+	tags     thinkTagParser
+}
+
+func newThinkingSplitter(think bool, strategy string) *thinkingSplitter {
+	return &thinkingSplitter{think: think, strategy: strategy}
+}
+
+// split returns the thinking and content parts of a message or delta.
+func (s *thinkingSplitter) split(reasoning, content string) (thinking, answer string) {
+	answer = content
+	if (s.strategy == "split" && s.think) || s.strategy == "strip" {
+		var tagged string
+		tagged, answer = s.tags.feed(content)
+		reasoning += tagged
+	}
+	if !s.think {
+		return "", answer
+	}
+	return reasoning, answer
+}
+
+// flush returns text the tag parser held back at the end of the response.
+func (s *thinkingSplitter) flush() (thinking, answer string) {
+	thinking, answer = s.tags.flush()
+	if !s.think {
+		return "", answer
+	}
+	return thinking, answer
+}
+
+// thinkTagParser splits streamed content on a leading <think> block, the way
+// reasoning models emit it. Tags later in the answer are left alone. A tag can
+// be cut across chunks, so text that might be part of one is held back until
+// the next chunk arrives. Whitespace around the block is dropped.
+type thinkTagParser struct {
+	state   thinkTagState
+	pending string
+}
+
+type thinkTagState int
+
+const (
+	beforeThink   thinkTagState = iota // Waiting for the opening tag
+	inThink                            // Inside the block
+	beforeContent                      // Dropping whitespace after the block
+	afterThink                         // Everything else is content
+)
+
+// feed consumes the next piece of content.
+func (p *thinkTagParser) feed(text string) (thinking, content string) {
+	text = p.pending + text
+	p.pending = ""
+
+	if p.state == beforeThink {
+		trimmed := strings.TrimLeftFunc(text, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			text = strings.TrimLeftFunc(trimmed[len(thinkOpenTag):], unicode.IsSpace)
+			p.state = inThink
+		case strings.HasPrefix(thinkOpenTag, trimmed):
+			p.pending = text // Blank or a partial tag so far
+			return "", ""
+		default:
+			p.state = afterThink
+		}
+	}
+
+	if p.state == inThink {
+		if i := strings.Index(text, thinkCloseTag); i >= 0 {
+			p.state = afterThink
+			thinking = strings.TrimRightFunc(text[:i], unicode.IsSpace)
+			content = strings.TrimLeftFunc(text[i+len(thinkCloseTag):], unicode.IsSpace)
+			if content == "" {
+				p.state = beforeContent
+			}
+			return thinking, content
+		}
+		keep := partialTagSuffix(text, thinkCloseTag)
+		p.pending = text[len(text)-keep:]
+		return text[:len(text)-keep], ""
+	}
+
+	if p.state == beforeContent {
+		text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		if text == "" {
+			return "", ""
+		}
+		p.state = afterThink
+	}
+	return "", text
+}
+
+// flush returns the held back text once the response is complete.
+func (p *thinkTagParser) flush() (thinking, content string) {
+	pending := p.pending
+	p.pending = ""
+	if p.state == inThink {
+		return pending, ""
+	}
+	return "", pending
+}
+
+// partialTagSuffix returns the length of the longest suffix of text that is a
+// proper prefix of tag.
+func partialTagSuffix(text, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
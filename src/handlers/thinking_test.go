@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseThink(t *testing.T) {
+	tests := []struct {
+		raw     string
+		enabled bool
+		effort  string
+		wantErr bool
+	}{
+		{``, false, "", false},
+		{`null`, false, "", false},
+		{`true`, true, "", false},
+		{`false`, false, "", false},
+		{`"high"`, true, "high", false},
+		{`"extreme"`, false, "", true},
+		{`1`, false, "", true},
+	}
+	for _, tt := range tests {
+		enabled, effort, err := parseThink(json.RawMessage(tt.raw))
+		if enabled != tt.enabled || effort != tt.effort || (err != nil) != tt.wantErr {
+			t.Errorf("parseThink(%q) = %v, %q, %v", tt.raw, enabled, effort, err)
+		}
+	}
+}
+
+func TestReasoningEffort(t *testing.T) {
+	reasoningModels := []string{"o3*"}
+	if got := reasoningEffort("o3-mini", true, "", reasoningModels); got != "medium" {
+		t.Errorf("Expected default effort for think=true, got %q", got)
+	}
+	if got := reasoningEffort("o3-mini", true, "low", reasoningModels); got != "low" {
+		t.Errorf("Expected requested effort, got %q", got)
+	}
+	if got := reasoningEffort("gpt-4o", true, "high", reasoningModels); got != "" {
+		t.Errorf("Expected no effort for a model without reasoning_effort, got %q", got)
+	}
+	if got := reasoningEffort("o3-mini", false, "", reasoningModels); got != "" {
+		t.Errorf("Expected no effort without think, got %q", got)
+	}
+}
+
+// splitAll feeds chunks through a splitter and collects its output.
+func splitAll(s *thinkingSplitter, chunks ...string) (thinking, content string) {
+	for _, chunk := range chunks {
+		t, c := s.split("", chunk)
+		thinking += t
+		content += c
+	}
+	t, c := s.flush()
+	return thinking + t, content + c
+}
+
+func TestThinkingSplitter_Tags(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		thinking string
+		content  string
+	}{
+		{"single chunk", []string{"<think>Hmm.</think>\n\nHello"}, "Hmm.", "Hello"},
+		{"tags split across chunks", []string{"\n<th", "ink>\nLet me ", "see</th", "ink>", "\n\n", "Hi <think> there"}, "Let me see", "Hi <think> there"},
+		{"no block", []string{"Plain ", "answer"}, "", "Plain answer"},
+		{"unterminated block", []string{"<think>Still going"}, "Still going", ""},
+		{"partial tag at end", []string{"<thi"}, "", "<thi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thinking, content := splitAll(newThinkingSplitter(true, "split"), tt.chunks...)
+			if thinking != tt.thinking || content != tt.content {
+				t.Errorf("Got thinking %q and content %q, want %q and %q", thinking, content, tt.thinking, tt.content)
+			}
+		})
+	}
+}
+
+func TestThinkingSplitter_Strategies(t *testing.T) {
+	response := "<think>Hmm.</think>Hello"
+
+	if thinking, content := splitAll(newThinkingSplitter(false, "split"), response); thinking != "" || content != response {
+		t.Errorf("split without think: got %q, %q", thinking, content)
+	}
+	if thinking, content := splitAll(newThinkingSplitter(false, "strip"), response); thinking != "" || content != "Hello" {
+		t.Errorf("strip without think: got %q, %q", thinking, content)
+	}
+	if thinking, content := splitAll(newThinkingSplitter(true, "none"), response); thinking != "" || content != response {
+		t.Errorf("none: got %q, %q", thinking, content)
+	}
+
+	// Reasoning fields are only returned when thinking is enabled
+	if thinking, _ := newThinkingSplitter(true, "none").split("Because.", ""); thinking != "Because." {
+		t.Errorf("Expected reasoning field as thinking, got %q", thinking)
+	}
+	if thinking, _ := newThinkingSplitter(false, "split").split("Because.", ""); thinking != "" {
+		t.Errorf("Expected reasoning to be dropped without think, got %q", thinking)
+	}
+}
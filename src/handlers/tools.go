@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"ollama-openai-proxy/src/models"
+)
+
+// toOpenAITools converts Ollama tool definitions into OpenAI tools.
+func toOpenAITools(tools []models.OllamaTool) []models.OpenAITool {
+	if len(tools) == 0 {
+		return nil
+	}
+	openAITools := make([]models.OpenAITool, len(tools))
+	for i, tool := range tools {
+		toolType := tool.Type
+		if toolType == "" {
+			toolType = "function"
+		}
+		openAITools[i] = models.OpenAITool{
+			Type: toolType,
+			Function: models.OpenAIToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		}
+	}
+	return openAITools
+}
+
+// toOllamaToolCalls converts complete OpenAI tool calls into Ollama tool calls.
+func toOllamaToolCalls(calls []models.OpenAIToolCall) []models.OllamaToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	ollamaCalls := make([]models.OllamaToolCall, len(calls))
+	for i, call := range calls {
+		ollamaCalls[i] = models.OllamaToolCall{
+			ID: call.ID,
+			Function: models.OllamaToolCallFunction{
+				Index:     i,
+				Name:      call.Function.Name,
+				Arguments: toolArgumentsObject(call.Function.Arguments),
+			},
+		}
+	}
+	return ollamaCalls
+}
+
+// toolArgumentsString encodes Ollama's argument object the way OpenAI expects it.
+func toolArgumentsString(arguments json.RawMessage) string {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		return "{}"
+	}
+	// Some clients already send the arguments as an encoded string
+	var encoded string
+	if json.Unmarshal(arguments, &encoded) == nil {
+		return encoded
+	}
+	return string(arguments)
+}
+
+// toolArgumentsObject decodes OpenAI's argument string into the object Ollama
+// clients expect. Arguments that aren't valid JSON are replaced by an empty object.
+func toolArgumentsObject(arguments string) json.RawMessage {
+	if arguments == "" {
+		return json.RawMessage("{}")
+	}
+	if !json.Valid([]byte(arguments)) {
+		log.Printf("Tool call arguments are not valid JSON: %s", arguments)
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// toolCallAccumulator reassembles tool calls from OpenAI stream deltas, where
+// the name and ID arrive first and the arguments follow in fragments.
+type toolCallAccumulator struct {
+	calls []models.OpenAIToolCall
+}
+
+// add merges a delta into the calls collected so far.
+func (a *toolCallAccumulator) add(deltas []models.OpenAIToolCall) {
+	for _, delta := range deltas {
+		index := len(a.calls) - 1
+		switch {
+		case delta.Index != nil:
+			index = *delta.Index
+		case delta.ID != "" || index < 0:
+			// Providers that omit the index send every call in one piece
+			index = len(a.calls)
+		}
+		for len(a.calls) <= index {
+			a.calls = append(a.calls, models.OpenAIToolCall{Type: "function"})
+		}
+
+		call := &a.calls[index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+// flush returns the collected calls in Ollama format and resets the accumulator.
+func (a *toolCallAccumulator) flush() []models.OllamaToolCall {
+	calls := toOllamaToolCalls(a.calls)
+	a.calls = nil
+	return calls
+}
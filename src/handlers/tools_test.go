@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	"ollama-openai-proxy/src/models"
+)
+
+func TestToolCallAccumulator_WithoutIndex(t *testing.T) {
+	// Some OpenAI-compatible providers send complete calls without an index
+	var acc toolCallAccumulator
+	acc.add([]models.OpenAIToolCall{
+		{ID: "a", Function: models.OpenAIToolCallFunction{Name: "first", Arguments: `{"x":1}`}},
+		{ID: "b", Function: models.OpenAIToolCallFunction{Name: "second", Arguments: `{}`}},
+	})
+
+	calls := acc.flush()
+	if len(calls) != 2 || calls[0].Function.Name != "first" || calls[1].ID != "b" {
+		t.Fatalf("Unexpected calls: %+v", calls)
+	}
+	if string(calls[0].Function.Arguments) != `{"x":1}` {
+		t.Errorf("Unexpected arguments: %s", calls[0].Function.Arguments)
+	}
+	if len(acc.flush()) != 0 {
+		t.Errorf("Expected accumulator to be empty after flush")
+	}
+}
+
+func TestToolArgumentsObject_Invalid(t *testing.T) {
+	if got := string(toolArgumentsObject(`{"city":`)); got != "{}" {
+		t.Errorf("Expected invalid arguments to become an empty object, got %s", got)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 
+	"ollama-openai-proxy/src/config"
+	"ollama-openai-proxy/src/middleware"
 	"ollama-openai-proxy/src/models"
 )
 
@@ -41,23 +43,26 @@ func TestGetVersionHandler_Success(t *testing.T) {
 	}
 }
 
-func TestGetVersionHandler_MissingAuthHeader(t *testing.T) {
-	req, err := http.NewRequest("GET", "/api/version", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	// No Authorization header set
+func TestGetVersionHandler_TokensModeWithBackendKeys(t *testing.T) {
+	// Every backend has its own key, so the middleware removes the header
+	cfg := config.AppConfig{AuthMode: config.AuthModeTokens, ProxyAccessTokens: []string{"alice"}}
+	handler := middleware.AuthMiddleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		GetVersionHandler(w, r, "0.0.1")
+	}))
 
+	req, _ := http.NewRequest("GET", "/api/version", nil)
+	req.Header.Set("Authorization", "Bearer alice")
 	rr := httptest.NewRecorder()
-	// The openAIBaseURL and allowedModelsList don't matter as auth should fail first
-	GetVersionHandler(rr, req, "dummy")
-
-	if status := rr.Code; status != http.StatusUnauthorized {
-		t.Errorf("Handler returned wrong status code for missing auth: got %v want %v. Body: %s", status, http.StatusUnauthorized, rr.Body.String())
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "0.0.1") {
+		t.Errorf("Expected the version, got %d: %s", rr.Code, rr.Body.String())
 	}
-	expectedErrorMsg := "Unauthorized: Missing Authorization header"
-	if !strings.Contains(rr.Body.String(), expectedErrorMsg) {
-		t.Errorf("Handler returned wrong error message: got '%s', expected to contain '%s'", rr.Body.String(), expectedErrorMsg)
+
+	req, _ = http.NewRequest("GET", "/api/version", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected clients without a token to be rejected, got %d", rr.Code)
 	}
 }
 
@@ -0,0 +1,328 @@
+// Package jsonschema validates JSON documents against the subset of JSON
+// Schema used for structured outputs.
+//
+// Supported keywords: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf,
+// oneOf, not and local $ref (#/$defs/... and #/definitions/...). Unknown
+// keywords are ignored.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError describes where a document violates the schema.
+type ValidationError struct {
+	Path    string // JSONPath-like location, e.g. $.items[0].name
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks that document is valid JSON matching schema.
+func Validate(schema json.RawMessage, document []byte) error {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	var instance interface{}
+	if err := json.Unmarshal(document, &instance); err != nil {
+		return &ValidationError{Path: "$", Message: "not valid JSON"}
+	}
+	v := validator{root: root}
+	return v.validate(root, instance, "$", 0)
+}
+
+// maxRefDepth stops infinitely recursive $ref chains.
+const maxRefDepth = 64
+
+type validator struct {
+	root interface{}
+}
+
+func (v validator) validate(schema interface{}, instance interface{}, path string, depth int) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return &ValidationError{Path: path, Message: "no value is allowed here"}
+		}
+		return nil
+	case map[string]interface{}:
+		return v.validateObjectSchema(s, instance, path, depth)
+	default:
+		return fmt.Errorf("invalid schema at %s", path)
+	}
+}
+
+func (v validator) validateObjectSchema(s map[string]interface{}, instance interface{}, path string, depth int) error {
+	if ref, ok := s["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			return fmt.Errorf("$ref nesting too deep at %s", path)
+		}
+		target, err := v.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := v.validate(target, instance, path, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := s["type"]; ok {
+		if err := checkType(t, instance, path); err != nil {
+			return err
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if reflect.DeepEqual(candidate, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value %s is not one of the allowed values", describe(instance))}
+		}
+	}
+	if constant, ok := s["const"]; ok && !reflect.DeepEqual(constant, instance) {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value must be %s", describe(constant))}
+	}
+
+	switch value := instance.(type) {
+	case map[string]interface{}:
+		if err := v.validateProperties(s, value, path, depth); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := v.validateItems(s, value, path, depth); err != nil {
+			return err
+		}
+	case string:
+		if err := validateString(s, value, path); err != nil {
+			return err
+		}
+	case float64:
+		if err := validateNumber(s, value, path); err != nil {
+			return err
+		}
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if err := v.validate(sub, instance, path, depth); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		if v.countMatches(anyOf, instance, path, depth) == 0 {
+			return &ValidationError{Path: path, Message: "value does not match any of the allowed schemas"}
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		if matches := v.countMatches(oneOf, instance, path, depth); matches != 1 {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("value must match exactly one schema, matched %d", matches)}
+		}
+	}
+	if not, ok := s["not"]; ok {
+		if v.validate(not, instance, path, depth) == nil {
+			return &ValidationError{Path: path, Message: "value matches a forbidden schema"}
+		}
+	}
+	return nil
+}
+
+func (v validator) validateProperties(s map[string]interface{}, value map[string]interface{}, path string, depth int) error {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := value[key]; !present {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", key)}
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Report errors deterministically
+
+	for _, key := range keys {
+		propertyPath := path + "." + key
+		if propertySchema, ok := properties[key]; ok {
+			if err := v.validate(propertySchema, value[key], propertyPath, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if additional, ok := s["additionalProperties"]; ok {
+			if allowed, isBool := additional.(bool); isBool && !allowed {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("unexpected property %q", key)}
+			}
+			if err := v.validate(additional, value[key], propertyPath, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v validator) validateItems(s map[string]interface{}, value []interface{}, path string, depth int) error {
+	if lo, ok := s["minItems"].(float64); ok && float64(len(value)) < lo {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at least %g items, got %d", lo, len(value))}
+	}
+	if hi, ok := s["maxItems"].(float64); ok && float64(len(value)) > hi {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected at most %g items, got %d", hi, len(value))}
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range value {
+			if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateString(s map[string]interface{}, value string, path string) error {
+	length := float64(utf8.RuneCountInString(value))
+	if lo, ok := s["minLength"].(float64); ok && length < lo {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("string shorter than %g characters", lo)}
+	}
+	if hi, ok := s["maxLength"].(float64); ok && length > hi {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("string longer than %g characters", hi)}
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q in schema: %w", pattern, err)
+		}
+		if !re.MatchString(value) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("string does not match pattern %q", pattern)}
+		}
+	}
+	return nil
+}
+
+func validateNumber(s map[string]interface{}, value float64, path string) error {
+	if lo, ok := s["minimum"].(float64); ok && value < lo {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %g is less than %g", value, lo)}
+	}
+	if hi, ok := s["maximum"].(float64); ok && value > hi {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %g is greater than %g", value, hi)}
+	}
+	if lo, ok := s["exclusiveMinimum"].(float64); ok && value <= lo {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %g must be greater than %g", value, lo)}
+	}
+	if hi, ok := s["exclusiveMaximum"].(float64); ok && value >= hi {
+		return &ValidationError{Path: path, Message: fmt.Sprintf("value %g must be less than %g", value, hi)}
+	}
+	return nil
+}
+
+func (v validator) countMatches(schemas []interface{}, instance interface{}, path string, depth int) int {
+	matches := 0
+	for _, sub := range schemas {
+		if v.validate(sub, instance, path, depth) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+// resolve follows a local JSON pointer reference such as #/$defs/Item.
+func (v validator) resolve(ref string) (interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+	current := v.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return current, nil
+}
+
+// checkType validates the "type" keyword, given as a string or a list of types.
+func checkType(t interface{}, instance interface{}, path string) error {
+	var types []string
+	switch tv := t.(type) {
+	case string:
+		types = []string{tv}
+	case []interface{}:
+		for _, item := range tv {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for _, name := range types {
+		if hasType(name, instance) {
+			return nil
+		}
+	}
+	return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeOf(instance))}
+}
+
+func hasType(name string, instance interface{}) bool {
+	switch name {
+	case "integer":
+		n, ok := instance.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := instance.(float64)
+		return ok
+	default:
+		return typeOf(instance) == name
+	}
+}
+
+func typeOf(instance interface{}) string {
+	switch instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func describe(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
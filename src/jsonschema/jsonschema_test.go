@@ -0,0 +1,90 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"address": {"$ref": "#/$defs/Address"},
+		"nickname": {"type": ["string", "null"]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"Address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errText  string
+	}{
+		{name: "valid", document: `{"name":"Ada","age":36,"role":"admin","tags":["x"],"address":{"city":"London"},"nickname":null}`},
+		{name: "not json", document: `{"name":`, errText: "$: not valid JSON"},
+		{name: "wrong root type", document: `[]`, errText: "$: expected object, got array"},
+		{name: "missing required", document: `{"name":"Ada"}`, errText: `missing required property "age"`},
+		{name: "not an integer", document: `{"name":"Ada","age":1.5}`, errText: "$.age: expected integer, got number"},
+		{name: "below minimum", document: `{"name":"Ada","age":-1}`, errText: "$.age: value -1 is less than 0"},
+		{name: "empty string", document: `{"name":"","age":1}`, errText: "$.name: string shorter than 1"},
+		{name: "enum", document: `{"name":"Ada","age":1,"role":"root"}`, errText: `$.role: value "root" is not one of the allowed values`},
+		{name: "array item", document: `{"name":"Ada","age":1,"tags":[1]}`, errText: "$.tags[0]: expected string, got number"},
+		{name: "too many items", document: `{"name":"Ada","age":1,"tags":["a","b","c"]}`, errText: "$.tags: expected at most 2 items"},
+		{name: "ref", document: `{"name":"Ada","age":1,"address":{}}`, errText: `$.address: missing required property "city"`},
+		{name: "additional property", document: `{"name":"Ada","age":1,"email":"a@b.c"}`, errText: `unexpected property "email"`},
+		{name: "type list", document: `{"name":"Ada","age":1,"nickname":5}`, errText: "$.nickname: expected string or null, got number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(personSchema), []byte(tt.document))
+			if tt.errText == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	schema := `{"anyOf":[{"type":"string"},{"type":"number"}],"not":{"const":"forbidden"}}`
+	if err := Validate([]byte(schema), []byte(`"ok"`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := Validate([]byte(schema), []byte(`true`)); err == nil {
+		t.Error("Expected anyOf mismatch")
+	}
+	if err := Validate([]byte(schema), []byte(`"forbidden"`)); err == nil {
+		t.Error("Expected not to reject the forbidden value")
+	}
+
+	oneOf := `{"oneOf":[{"type":"integer"},{"type":"number"}]}`
+	if err := Validate([]byte(oneOf), []byte(`1.5`)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := Validate([]byte(oneOf), []byte(`1`)); err == nil {
+		t.Error("Expected an integer to match both oneOf schemas")
+	}
+}
+
+func TestValidate_InvalidSchema(t *testing.T) {
+	if err := Validate([]byte(`{"type":`), []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "invalid schema") {
+		t.Errorf("Expected invalid schema error, got %v", err)
+	}
+	if err := Validate([]byte(`{"$ref":"https://example.com/schema.json"}`), []byte(`{}`)); err == nil {
+		t.Error("Expected remote $ref to be rejected")
+	}
+}
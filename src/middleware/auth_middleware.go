@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// AuthMiddleware authenticates clients according to cfg.AuthMode.
+//
+// In passthrough mode requests are left untouched and handlers forward the
+// client's Authorization header upstream. In tokens mode the client must send
+// one of the proxy access tokens as a Bearer token; in none mode every client
+// is accepted. In both of these the client's header is replaced by the
+// upstream API key, so handlers always forward the right credentials and the
+// access token never leaves the proxy. When every backend has its own key and
+// there is no global one, the header is removed instead.
+func AuthMiddleware(cfg config.AppConfig, next http.Handler) http.Handler {
+	if cfg.AuthMode == config.AuthModePassthrough {
+		return next
+	}
+	upstreamAuth := "Bearer " + cfg.OpenAIAPIKey
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AuthMode == config.AuthModeTokens {
+			token, ok := bearerToken(r.Header.Get("Authorization"))
+			if !ok {
+				http.Error(w, "Unauthorized: Missing Authorization header", http.StatusUnauthorized)
+				return
+			}
+			if !validToken(token, cfg.ProxyAccessTokens) {
+				log.Printf("Rejected invalid access token from %s", r.RemoteAddr)
+				http.Error(w, "Unauthorized: Invalid access token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if cfg.OpenAIAPIKey == "" {
+			r.Header.Del("Authorization")
+		} else {
+			r.Header.Set("Authorization", upstreamAuth)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(header string) (string, bool) {
+	if len(header) <= 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
+
+// validToken compares token with every access token in constant time.
+func validToken(token string, accessTokens []string) bool {
+	valid := false
+	for _, accessToken := range accessTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(accessToken)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
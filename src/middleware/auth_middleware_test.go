@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// upstreamAuth records the Authorization header the wrapped handler sees.
+func upstreamAuth(seen *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = r.Header.Get("Authorization")
+	})
+}
+
+func TestAuthMiddleware_Tokens(t *testing.T) {
+	cfg := config.AppConfig{
+		AuthMode:          config.AuthModeTokens,
+		OpenAIAPIKey:      "sk-upstream",
+		ProxyAccessTokens: []string{"alice", "bob"},
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"valid token", "Bearer bob", http.StatusOK},
+		{"lowercase scheme", "bearer alice", http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"wrong token", "Bearer mallory", http.StatusUnauthorized},
+		{"upstream key", "Bearer sk-upstream", http.StatusUnauthorized},
+		{"not bearer", "Basic Ym9i", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			req := httptest.NewRequest("POST", "/api/chat", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			AuthMiddleware(cfg, upstreamAuth(&seen)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Got status %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && seen != "Bearer sk-upstream" {
+				t.Errorf("Expected upstream key to be injected, handler saw %q", seen)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_None(t *testing.T) {
+	cfg := config.AppConfig{AuthMode: config.AuthModeNone, OpenAIAPIKey: "sk-upstream"}
+
+	var seen string
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	rr := httptest.NewRecorder()
+	AuthMiddleware(cfg, upstreamAuth(&seen)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || seen != "Bearer sk-upstream" {
+		t.Errorf("Expected request to pass with the upstream key, got status %d and %q", rr.Code, seen)
+	}
+}
+
+func TestAuthMiddleware_Passthrough(t *testing.T) {
+	cfg := config.AppConfig{AuthMode: config.AuthModePassthrough, OpenAIAPIKey: "sk-upstream"}
+
+	var seen string
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Authorization", "Bearer sk-client")
+	rr := httptest.NewRecorder()
+	AuthMiddleware(cfg, upstreamAuth(&seen)).ServeHTTP(rr, req)
+
+	if seen != "Bearer sk-client" {
+		t.Errorf("Expected the client's header to be forwarded, got %q", seen)
+	}
+}
+
+func TestAuthMiddleware_BackendKeysOnly(t *testing.T) {
+	// Every backend has its own key, there is no global one to inject
+	cfg := config.AppConfig{AuthMode: config.AuthModeTokens, ProxyAccessTokens: []string{"alice"}}
+
+	seen := "unset"
+	req := httptest.NewRequest("GET", "/api/tags", nil)
+	req.Header.Set("Authorization", "Bearer alice")
+	rr := httptest.NewRecorder()
+	AuthMiddleware(cfg, upstreamAuth(&seen)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || seen != "" {
+		t.Errorf("Expected the access token to be removed, got status %d and %q", rr.Code, seen)
+	}
+}
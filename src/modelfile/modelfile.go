@@ -0,0 +1,140 @@
+// Package modelfile parses Ollama Modelfiles.
+package modelfile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Modelfile holds the directives of a Modelfile that make sense for a model
+// served by an upstream API.
+type Modelfile struct {
+	From     string
+	System   string
+	Template string
+	License  string
+	// Parameters are Ollama options as they would be sent in a request:
+	// numbers are float64, stop is a list of strings.
+	Parameters map[string]interface{}
+	Messages   []Message
+}
+
+// Message is a MESSAGE directive.
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Parse reads a Modelfile. Commands are case-insensitive, lines starting with
+// # are comments and values may be quoted with " or, across lines, with """.
+// ADAPTER is rejected as there are no local weights to apply it to. FROM may
+// be missing when the model is given elsewhere, e.g. in the fields of an
+// /api/create request, in which case From is empty.
+func Parse(text string) (*Modelfile, error) {
+	mf := &Modelfile{Parameters: make(map[string]interface{})}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lineNo := i + 1
+		command, args := cutSpace(line)
+		command = strings.ToUpper(command)
+
+		// PARAMETER and MESSAGE take a name before their value
+		var name string
+		if command == "PARAMETER" || command == "MESSAGE" {
+			name, args = cutSpace(args)
+			name = strings.ToLower(name)
+		}
+		value, err := readValue(args, lines, &i)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch command {
+		case "FROM":
+			if mf.From != "" {
+				return nil, fmt.Errorf("line %d: only one FROM is supported", lineNo)
+			}
+			mf.From = value
+		case "SYSTEM":
+			mf.System = value
+		case "TEMPLATE":
+			mf.Template = value
+		case "LICENSE":
+			mf.License = value
+		case "PARAMETER":
+			if name == "" || value == "" {
+				return nil, fmt.Errorf("line %d: PARAMETER needs a name and a value", lineNo)
+			}
+			addParameter(mf.Parameters, name, value)
+		case "MESSAGE":
+			if name == "" {
+				return nil, fmt.Errorf("line %d: MESSAGE needs a role", lineNo)
+			}
+			mf.Messages = append(mf.Messages, Message{Role: name, Content: value})
+		case "ADAPTER":
+			return nil, fmt.Errorf("line %d: ADAPTER is not supported", lineNo)
+		default:
+			return nil, fmt.Errorf("line %d: unknown command %s", lineNo, command)
+		}
+	}
+	return mf, nil
+}
+
+// cutSpace splits s at its first run of white space.
+func cutSpace(s string) (before, after string) {
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// readValue unquotes the value of a command. A value opened with """ may
+// continue on the following lines, which *i is advanced past.
+func readValue(value string, lines []string, i *int) (string, error) {
+	if rest, ok := strings.CutPrefix(value, `"""`); ok {
+		var b strings.Builder
+		for {
+			if text, trailing, closed := strings.Cut(rest, `"""`); closed {
+				if strings.TrimSpace(trailing) != "" {
+					return "", fmt.Errorf("unexpected text after closing \"\"\": %s", trailing)
+				}
+				b.WriteString(text)
+				return b.String(), nil
+			}
+			b.WriteString(rest)
+			*i++
+			if *i >= len(lines) {
+				return "", errors.New(`unterminated """`)
+			}
+			b.WriteString("\n")
+			rest = lines[*i]
+		}
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1], nil
+	}
+	return value, nil
+}
+
+// addParameter stores a parameter with the type its value has in JSON.
+// Repeated stop parameters add up to a list.
+func addParameter(parameters map[string]interface{}, name, value string) {
+	if name == "stop" {
+		stop, _ := parameters["stop"].([]interface{})
+		parameters["stop"] = append(stop, value)
+		return
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		parameters[name] = number
+	} else if boolean, err := strconv.ParseBool(value); err == nil {
+		parameters[name] = boolean
+	} else {
+		parameters[name] = value
+	}
+}
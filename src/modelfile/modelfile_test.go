@@ -0,0 +1,70 @@
+package modelfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	mf, err := Parse(`# A code reviewer
+FROM gpt-4o
+parameter temperature 0.2
+PARAMETER num_ctx 8192
+PARAMETER stop "<|end|>"
+PARAMETER stop END
+PARAMETER use_mmap false
+SYSTEM """You review code.
+Point out bugs first."""
+TEMPLATE "{{ .Prompt }}"
+MESSAGE user Is this safe?
+MESSAGE assistant """
+No."""
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := &Modelfile{
+		From:     "gpt-4o",
+		System:   "You review code.\nPoint out bugs first.",
+		Template: "{{ .Prompt }}",
+		Parameters: map[string]interface{}{
+			"temperature": 0.2,
+			"num_ctx":     float64(8192),
+			"stop":        []interface{}{"<|end|>", "END"},
+			"use_mmap":    false,
+		},
+		Messages: []Message{{Role: "user", Content: "Is this safe?"}, {Role: "assistant", Content: "\nNo."}},
+	}
+	if !reflect.DeepEqual(mf, want) {
+		t.Errorf("Got %+v, want %+v", mf, want)
+	}
+}
+
+func TestParse_WithoutFrom(t *testing.T) {
+	mf, err := Parse("SYSTEM hi")
+	if err != nil || mf.From != "" || mf.System != "hi" {
+		t.Errorf("Unexpected result: %+v (err: %v)", mf, err)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"two FROMs", "FROM a\nFROM b"},
+		{"unknown command", "FROM a\nSYSTEMS hi"},
+		{"adapter", "FROM a\nADAPTER ./lora.gguf"},
+		{"parameter without value", "FROM a\nPARAMETER temperature"},
+		{"message without role", "FROM a\nMESSAGE"},
+		{"unterminated quotes", "FROM a\nSYSTEM \"\"\"You\nreview code."},
+		{"text after quotes", "FROM a\nSYSTEM \"\"\"You\"\"\" review"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.text); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import "encoding/json"
+
+// AnthropicMessagesRequest matches the request structure for Anthropic's /v1/messages.
+type AnthropicMessagesRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"` // Required by Anthropic
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Thinking      *AnthropicThinking `json:"thinking,omitempty"`
+}
+
+// AnthropicMessage is a single message. Roles must alternate between "user"
+// and "assistant"; system prompts go into the request's System field.
+type AnthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []AnthropicContentBlock `json:"content"`
+}
+
+// AnthropicContentBlock is a block of message content. Type decides which
+// fields are used: "text", "image", "tool_use", "tool_result" or "thinking".
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Image
+	Source *AnthropicImageSource `json:"source,omitempty"`
+	// Tool use, in assistant messages
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Tool result, in user messages
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	// Thinking, in responses
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// AnthropicImageSource holds an image as base64 data or a URL.
+type AnthropicImageSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicTool describes a tool the model may use.
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// AnthropicThinking enables extended thinking with a token budget.
+type AnthropicThinking struct {
+	Type         string `json:"type"` // "enabled"
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// AnthropicUsage reports the tokens used by a request. In streams the input
+// tokens arrive with message_start and the output tokens with message_delta.
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicMessagesResponse is a complete, non-streaming response.
+type AnthropicMessagesResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Role       string                  `json:"role"`
+	Content    []AnthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"` // "end_turn", "max_tokens", "stop_sequence" or "tool_use"
+	Usage      AnthropicUsage          `json:"usage"`
+}
+
+// AnthropicStreamEvent is the data of a streamed event. Type names the event
+// and decides which fields are set.
+type AnthropicStreamEvent struct {
+	Type         string                     `json:"type"`
+	Message      *AnthropicMessagesResponse `json:"message,omitempty"`       // message_start
+	Index        int                        `json:"index"`                   // content_block_*
+	ContentBlock *AnthropicContentBlock     `json:"content_block,omitempty"` // content_block_start
+	Delta        *AnthropicStreamDelta      `json:"delta,omitempty"`         // content_block_delta, message_delta
+	Usage        *AnthropicUsage            `json:"usage,omitempty"`         // message_delta
+	Error        *AnthropicError            `json:"error,omitempty"`         // error
+}
+
+// AnthropicStreamDelta is the change carried by a delta event.
+type AnthropicStreamDelta struct {
+	Type        string `json:"type,omitempty"` // "text_delta", "thinking_delta" or "input_json_delta"
+	Text        string `json:"text,omitempty"`
+	Thinking    string `json:"thinking,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"` // message_delta
+}
+
+// AnthropicError describes a failed request.
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// AnthropicModel is a single model listed by /v1/models.
+type AnthropicModel struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"` // RFC 3339
+}
+
+// AnthropicModelsResponse is the response of Anthropic's /v1/models.
+type AnthropicModelsResponse struct {
+	Data []AnthropicModel `json:"data"`
+}
@@ -1,32 +1,190 @@
 package models
 
+import "encoding/json"
+
 // OllamaChatMessage represents a single message in an Ollama chat request.
 type OllamaChatMessage struct {
-	Role    string   `json:"role"`
-	Content string   `json:"content"`
-	Images  []string `json:"images,omitempty"` // For multimodal support if needed in future
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"` // Reasoning, only returned when `think` is enabled
+	Images    []string         `json:"images,omitempty"`   // Base64 encoded images
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // Name of the tool a "tool" message answers
+}
+
+// OllamaToolCall represents a tool call made by the assistant in Ollama format.
+type OllamaToolCall struct {
+	ID       string                 `json:"id,omitempty"`
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+// OllamaToolCallFunction holds the called function. Unlike OpenAI, Ollama
+// sends the arguments as a JSON object rather than an encoded string.
+type OllamaToolCallFunction struct {
+	Index     int             `json:"index,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// OllamaTool represents a tool definition in an Ollama chat request.
+type OllamaTool struct {
+	Type     string             `json:"type"`
+	Function OllamaToolFunction `json:"function"`
+}
+
+// OllamaToolFunction describes a function the model may call.
+type OllamaToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
 
 // OllamaChatRequest represents the request body for Ollama's /api/chat.
 type OllamaChatRequest struct {
-	Model    string              `json:"model"`
-	Messages []OllamaChatMessage `json:"messages"`
-	Stream   bool                `json:"stream,omitempty"`
-	// Options map[string]interface{} `json:"options,omitempty"` // OpenAI doesn't use this directly
+	Model     string                 `json:"model"`
+	Messages  []OllamaChatMessage    `json:"messages"`
+	Tools     []OllamaTool           `json:"tools,omitempty"`
+	Stream    bool                   `json:"stream,omitempty"`
+	Format    json.RawMessage        `json:"format,omitempty"`     // "json" or a JSON schema
+	Think     json.RawMessage        `json:"think,omitempty"`      // true, false or "low", "medium", "high"
+	Options   map[string]interface{} `json:"options,omitempty"`    // Translated into OpenAISamplingParams
+	KeepAlive json.RawMessage        `json:"keep_alive,omitempty"` // A duration such as "5m" or seconds; only used by /api/ps
 }
 
 // OpenAIChatMessage matches the structure for messages in OpenAI API.
 type OpenAIChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"` // Set on "tool" messages
+	// Reasoning text of responses. Providers use either field name.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
+	// ContentParts replaces Content in requests when set, e.g. for images.
+	// Responses always carry plain string content.
+	ContentParts []OpenAIContentPart `json:"-"`
+}
+
+// MarshalJSON sends ContentParts as the message content when there are any.
+func (m OpenAIChatMessage) MarshalJSON() ([]byte, error) {
+	type message OpenAIChatMessage // Avoids recursing into MarshalJSON
+	if len(m.ContentParts) == 0 {
+		return json.Marshal(message(m))
+	}
+	return json.Marshal(struct {
+		message
+		Content []OpenAIContentPart `json:"content"`
+	}{message(m), m.ContentParts})
+}
+
+// UnmarshalJSON reads the content as a string or as an array of content parts.
+func (m *OpenAIChatMessage) UnmarshalJSON(data []byte) error {
+	type message OpenAIChatMessage // Avoids recursing into UnmarshalJSON
+	var raw struct {
+		message
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = OpenAIChatMessage(raw.message)
+	switch {
+	case len(raw.Content) == 0 || string(raw.Content) == "null":
+		return nil
+	case raw.Content[0] == '[':
+		return json.Unmarshal(raw.Content, &m.ContentParts)
+	}
+	return json.Unmarshal(raw.Content, &m.Content)
+}
+
+// OpenAIContentPart is a single part of a multimodal OpenAI message.
+type OpenAIContentPart struct {
+	Type     string          `json:"type"` // "text" or "image_url"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL references an image by URL or data URI.
+type OpenAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// OpenAIToolCall represents a tool call in an OpenAI message or stream delta.
+type OpenAIToolCall struct {
+	Index    *int                   `json:"index,omitempty"` // Only present in stream deltas
+	ID       string                 `json:"id,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	Function OpenAIToolCallFunction `json:"function"`
+}
+
+// OpenAIToolCallFunction holds the called function and its JSON encoded arguments.
+type OpenAIToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAITool represents a tool definition in an OpenAI chat request.
+type OpenAITool struct {
+	Type     string             `json:"type"`
+	Function OpenAIToolFunction `json:"function"`
+}
+
+// OpenAIToolFunction describes a function the model may call.
+type OpenAIToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// OpenAISamplingParams holds the sampling parameters shared by chat and legacy completion requests.
+type OpenAISamplingParams struct {
+	Temperature         *float64 `json:"temperature,omitempty"`
+	TopP                *float64 `json:"top_p,omitempty"`
+	MaxTokens           *int     `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int     `json:"max_completion_tokens,omitempty"`
+	Stop                []string `json:"stop,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+	PresencePenalty     *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    *float64 `json:"frequency_penalty,omitempty"`
+	// TopK has no OpenAI equivalent; it is only sent to backends that support it.
+	TopK *int `json:"-"`
 }
 
 // OpenAIChatRequest matches the request structure for OpenAI's /v1/chat/completions.
 type OpenAIChatRequest struct {
-	Model    string              `json:"model"`
-	Messages []OpenAIChatMessage `json:"messages"`
-	Stream   bool                `json:"stream,omitempty"`
-	// Other OpenAI specific parameters like temperature, max_tokens etc. can be added if needed.
+	Model          string                `json:"model"`
+	Messages       []OpenAIChatMessage   `json:"messages"`
+	Tools          []OpenAITool          `json:"tools,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"` // Only allowed when streaming
+	// ReasoningEffort is "low", "medium" or "high"; only reasoning models accept it.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	OpenAISamplingParams
+}
+
+// OpenAIStreamOptions asks for extra data in a streamed response.
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // Adds a final chunk with usage and no choices
+}
+
+// OpenAIUsage reports the tokens used by a request.
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// OpenAIResponseFormat requests structured output from OpenAI.
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"` // "json_object" or "json_schema"
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema wraps the schema of a "json_schema" response format.
+type OpenAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
 }
 
 // OpenAIChatChoice represents a choice in an OpenAI chat response.
@@ -44,7 +202,7 @@ type OpenAIChatResponse struct {
 	Created int64              `json:"created"`
 	Model   string             `json:"model"`
 	Choices []OpenAIChatChoice `json:"choices"`
-	// Usage   OpenAIUsage        `json:"usage"` // Optional: if usage data is needed
+	Usage   *OpenAIUsage       `json:"usage,omitempty"`
 }
 
 // OpenAIStreamChunk is for streaming responses (the structure of the data part of an SSE).
@@ -53,7 +211,8 @@ type OpenAIStreamChunk struct {
 	Object  string             `json:"object"`
 	Created int64              `json:"created"`
 	Model   string             `json:"model"`
-	Choices []OpenAIChatChoice `json:"choices"` // Delta will be populated here
+	Choices []OpenAIChatChoice `json:"choices"`         // Delta will be populated here
+	Usage   *OpenAIUsage       `json:"usage,omitempty"` // Only in the last chunk when requested
 }
 
 // OllamaChatResponse represents a non-streaming response from Ollama.
@@ -64,8 +223,7 @@ type OllamaChatResponse struct {
 	CreatedAt string            `json:"created_at"`
 	Message   OllamaChatMessage `json:"message"` // The complete assistant message
 	Done      bool              `json:"done"`
-	// Optional fields like total_duration, etc., if they can be obtained or are relevant.
-	// For simplicity, we'll focus on the core message.
+	*OllamaMetrics
 }
 
 // OllamaStreamChunk represents a streaming chunk in Ollama format.
@@ -74,5 +232,18 @@ type OllamaStreamChunk struct {
 	CreatedAt string            `json:"created_at"`
 	Message   OllamaChatMessage `json:"message"` // Contains the delta content
 	Done      bool              `json:"done"`    // False until the last chunk
-	// Optional: DoneReason string `json:"done_reason,omitempty"`
+	// Only set on the last chunk
+	*OllamaMetrics
+}
+
+// OllamaMetrics holds the statistics Ollama reports on its final message.
+// Durations are in nanoseconds.
+type OllamaMetrics struct {
+	DoneReason         string `json:"done_reason"`
+	TotalDuration      int64  `json:"total_duration"`
+	LoadDuration       int64  `json:"load_duration"`
+	PromptEvalCount    int    `json:"prompt_eval_count"`
+	PromptEvalDuration int64  `json:"prompt_eval_duration"`
+	EvalCount          int    `json:"eval_count"`
+	EvalDuration       int64  `json:"eval_duration"`
 }
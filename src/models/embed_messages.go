@@ -0,0 +1,62 @@
+package models
+
+import "encoding/json"
+
+// OllamaEmbedRequest represents the request body for Ollama's /api/embed.
+type OllamaEmbedRequest struct {
+	Model      string          `json:"model"`
+	Input      json.RawMessage `json:"input"`              // A single string or an array of strings
+	Truncate   *bool           `json:"truncate,omitempty"` // Ollama truncates by default
+	Dimensions int             `json:"dimensions,omitempty"`
+	KeepAlive  json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// OllamaEmbedResponse represents the response of Ollama's /api/embed.
+type OllamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float64 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	LoadDuration    int64       `json:"load_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+// OllamaEmbeddingsRequest represents the request body for Ollama's legacy /api/embeddings.
+type OllamaEmbeddingsRequest struct {
+	Model     string          `json:"model"`
+	Prompt    string          `json:"prompt"`
+	KeepAlive json.RawMessage `json:"keep_alive,omitempty"`
+}
+
+// OllamaEmbeddingsResponse represents the response of Ollama's legacy /api/embeddings.
+type OllamaEmbeddingsResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// OpenAIEmbeddingRequest matches the request structure for OpenAI's /v1/embeddings.
+type OpenAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+}
+
+// OpenAIEmbedding represents a single embedding in an OpenAI embeddings response.
+type OpenAIEmbedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// OpenAIEmbeddingUsage represents token usage of an OpenAI embeddings request.
+type OpenAIEmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// OpenAIEmbeddingResponse represents the response from OpenAI's /v1/embeddings.
+type OpenAIEmbeddingResponse struct {
+	Object string               `json:"object"`
+	Data   []OpenAIEmbedding    `json:"data"`
+	Model  string               `json:"model"`
+	Usage  OpenAIEmbeddingUsage `json:"usage"`
+}
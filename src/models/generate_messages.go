@@ -0,0 +1,57 @@
+package models
+
+import "encoding/json"
+
+// OllamaGenerateRequest represents the request body for Ollama's /api/generate.
+type OllamaGenerateRequest struct {
+	Model     string                 `json:"model"`
+	Prompt    string                 `json:"prompt"`
+	Suffix    string                 `json:"suffix,omitempty"`
+	System    string                 `json:"system,omitempty"`
+	Template  string                 `json:"template,omitempty"`
+	Raw       bool                   `json:"raw,omitempty"`
+	Context   []int                  `json:"context,omitempty"`
+	Images    []string               `json:"images,omitempty"`
+	Stream    *bool                  `json:"stream,omitempty"` // Ollama streams unless explicitly disabled
+	Format    json.RawMessage        `json:"format,omitempty"` // "json" or a JSON schema
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive json.RawMessage        `json:"keep_alive,omitempty"` // A duration such as "5m" or seconds; only used by /api/ps
+}
+
+// OllamaGenerateResponse represents both a streaming chunk and the final
+// non-streaming response of Ollama's /api/generate.
+type OllamaGenerateResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Response  string `json:"response"`
+	Done      bool   `json:"done"`
+	Context   []int  `json:"context,omitempty"` // Only set on the final message
+	*OllamaMetrics
+}
+
+// OpenAICompletionRequest matches the request structure for OpenAI's legacy /v1/completions.
+type OpenAICompletionRequest struct {
+	Model         string               `json:"model"`
+	Prompt        string               `json:"prompt"`
+	Suffix        string               `json:"suffix,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+	OpenAISamplingParams
+}
+
+// OpenAICompletionChoice represents a choice in an OpenAI completion response or stream chunk.
+type OpenAICompletionChoice struct {
+	Index        int    `json:"index"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// OpenAICompletionResponse is used for both non-streaming responses and stream chunks of /v1/completions.
+type OpenAICompletionResponse struct {
+	ID      string                   `json:"id"`
+	Object  string                   `json:"object"`
+	Created int64                    `json:"created"`
+	Model   string                   `json:"model"`
+	Choices []OpenAICompletionChoice `json:"choices"`
+	Usage   *OpenAIUsage             `json:"usage,omitempty"`
+}
@@ -29,3 +29,93 @@ type OllamaTagsResponse struct {
 type OllamaVersionResponse struct {
 	Version string `json:"version"`
 }
+
+// OllamaErrorResponse represents an error in Ollama format, either as a
+// response body or as a line of a stream.
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// OllamaCreateRequest represents the request body for Ollama's /api/create.
+// Older clients send a Modelfile and the name in `name`; newer ones send the
+// directives as fields.
+type OllamaCreateRequest struct {
+	Model      string                 `json:"model"`
+	Name       string                 `json:"name,omitempty"`
+	Modelfile  string                 `json:"modelfile,omitempty"`
+	From       string                 `json:"from,omitempty"`
+	System     string                 `json:"system,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Messages   []OllamaChatMessage    `json:"messages,omitempty"`
+	// Files, Adapters and Quantize need local weights and are rejected.
+	Files    map[string]string `json:"files,omitempty"`
+	Adapters map[string]string `json:"adapters,omitempty"`
+	Quantize string            `json:"quantize,omitempty"`
+	Stream   *bool             `json:"stream,omitempty"` // Ollama streams unless explicitly disabled
+}
+
+// OllamaStatusResponse is a progress message of /api/create and /api/pull.
+type OllamaStatusResponse struct {
+	Status string `json:"status"`
+}
+
+// OllamaShowRequest represents the request body for Ollama's /api/show.
+type OllamaShowRequest struct {
+	Model   string `json:"model"`
+	Name    string `json:"name,omitempty"` // Older clients
+	Verbose bool   `json:"verbose,omitempty"`
+}
+
+// OllamaShowResponse represents the response for Ollama's /api/show.
+type OllamaShowResponse struct {
+	Modelfile    string                 `json:"modelfile"`
+	Parameters   string                 `json:"parameters,omitempty"`
+	Template     string                 `json:"template"`
+	System       string                 `json:"system,omitempty"`
+	Details      OllamaModelDetails     `json:"details"`
+	Messages     []OllamaChatMessage    `json:"messages,omitempty"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+	ModifiedAt   string                 `json:"modified_at,omitempty"`
+}
+
+// OllamaRunningModel represents a model listed by Ollama's /api/ps. InFlight
+// and LastUsedAt are additions of the proxy.
+type OllamaRunningModel struct {
+	Name          string             `json:"name"`
+	Model         string             `json:"model"`
+	Size          int64              `json:"size"`
+	Digest        string             `json:"digest"`
+	Details       OllamaModelDetails `json:"details"`
+	ExpiresAt     string             `json:"expires_at"`
+	SizeVRAM      int64              `json:"size_vram"`
+	ContextLength int                `json:"context_length,omitempty"`
+	InFlight      int                `json:"in_flight"`
+	LastUsedAt    string             `json:"last_used_at"`
+}
+
+// OllamaPsResponse represents the response for Ollama's /api/ps endpoint.
+type OllamaPsResponse struct {
+	Models []OllamaRunningModel `json:"models"`
+}
+
+// OllamaCopyRequest represents the request body for Ollama's /api/copy.
+type OllamaCopyRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// OllamaDeleteRequest represents the request body for Ollama's /api/delete.
+type OllamaDeleteRequest struct {
+	Model string `json:"model"`
+	Name  string `json:"name,omitempty"` // Older clients
+}
+
+// OllamaPullRequest represents the request body for Ollama's /api/pull.
+type OllamaPullRequest struct {
+	Model    string `json:"model"`
+	Name     string `json:"name,omitempty"` // Older clients
+	Insecure bool   `json:"insecure,omitempty"`
+	Stream   *bool  `json:"stream,omitempty"` // Ollama streams unless explicitly disabled
+}
@@ -0,0 +1,125 @@
+// Package upstream provides the HTTP client shared by all requests the proxy
+// sends to OpenAI-compatible APIs.
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// Client sends upstream requests over a shared, pooled transport. Streaming
+// and non-streaming requests get separate total timeouts: a long stream is
+// normal, a non-streaming request that takes as long is not.
+type Client struct {
+	httpClient *http.Client
+	cfg        config.UpstreamConfig
+}
+
+// NewClient builds the client described by cfg.
+func NewClient(cfg config.UpstreamConfig) (*Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid upstream proxy URL %q", cfg.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   cfg.ConnectTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		ForceAttemptHTTP2:     true,
+	}
+	return &Client{httpClient: &http.Client{Transport: transport}, cfg: cfg}, nil
+}
+
+// newTLSConfig adds the custom CA bundle and client certificate, if any.
+func newTLSConfig(cfg config.UpstreamConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} // Opt-in, for development only
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading upstream CA file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("upstream CA file %s contains no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading upstream client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// Do sends req, retrying transient failures (see retry.go). Each attempt gets
+// the total timeout for streaming or non-streaming requests. The timeout keeps
+// running while the body is read and ends when it is closed.
+func (c *Client) Do(req *http.Request, stream bool) (*http.Response, error) {
+	return c.doWithRetries(req, stream)
+}
+
+// doOnce sends a single attempt of req.
+func (c *Client) doOnce(req *http.Request, stream bool) (*http.Response, error) {
+	timeout := c.cfg.Timeout
+	if stream {
+		timeout = c.cfg.StreamTimeout
+	}
+	if timeout <= 0 {
+		return c.httpClient.Do(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases the timeout context together with the response body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,133 @@
+package upstream
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+func newTestClient(t *testing.T, cfg config.UpstreamConfig) *Client {
+	t.Helper()
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func get(t *testing.T, client *Client, url string, stream bool) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client.Do(req, stream)
+}
+
+func TestClient_Timeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, config.UpstreamConfig{Timeout: 50 * time.Millisecond})
+
+	// The timeout covers reading the body, not just the headers
+	resp, err := get(t, client, server.URL, false)
+	if err == nil {
+		_, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the non-streaming request to time out, got %v", err)
+	}
+
+	// Streams are not limited by the non-streaming timeout
+	resp, err = get(t, client, server.URL, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, err := io.ReadAll(resp.Body); err != nil || string(body) != "done" {
+		t.Errorf("Expected the stream to complete, got %q (err: %v)", body, err)
+	}
+}
+
+func TestClient_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Without the CA the self-signed certificate is rejected
+	if _, err := get(t, newTestClient(t, config.UpstreamConfig{}), server.URL, false); err == nil {
+		t.Error("Expected an untrusted certificate to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := get(t, newTestClient(t, config.UpstreamConfig{CAFile: caFile}), server.URL, false)
+	if err != nil {
+		t.Fatalf("Expected the custom CA to be trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = get(t, newTestClient(t, config.UpstreamConfig{InsecureSkipVerify: true}), server.URL, false)
+	if err != nil {
+		t.Fatalf("Expected verification to be skipped: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClient_Proxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.URL.Host
+	}))
+	defer proxy.Close()
+
+	client := newTestClient(t, config.UpstreamConfig{ProxyURL: proxy.URL})
+	resp, err := get(t, client, "http://api.example.invalid/v1/models", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if proxiedHost != "api.example.invalid" {
+		t.Errorf("Expected the request to go through the proxy, proxy saw host %q", proxiedHost)
+	}
+}
+
+func TestNewClient_InvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name string
+		cfg  config.UpstreamConfig
+	}{
+		{"missing CA file", config.UpstreamConfig{CAFile: filepath.Join(dir, "missing.pem")}},
+		{"CA file without certificates", config.UpstreamConfig{CAFile: notPEM}},
+		{"invalid client certificate", config.UpstreamConfig{ClientCertFile: notPEM, ClientKeyFile: notPEM}},
+		{"invalid proxy URL", config.UpstreamConfig{ProxyURL: "not a url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClient(tt.cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// doWithRetries sends req and retries connection errors, 429 and 5xx
+// responses with exponential backoff and jitter. A successful response is
+// returned as soon as its headers arrive, so nothing is ever retried after
+// the handler started streaming it to the client.
+//
+// A request is only retried while its body can be replayed, the client is
+// still waiting and the total delay stays within the retry budget. Delays
+// requested by the server through Retry-After or x-ratelimit-reset-* headers
+// are honoured; if one exceeds the remaining budget the response is returned
+// as is.
+func (c *Client) doWithRetries(req *http.Request, stream bool) (*http.Response, error) {
+	budget := c.cfg.RetryBudget
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doOnce(req, stream)
+
+		if attempt >= c.cfg.MaxRetries || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			return resp, err // The body has been consumed and can't be sent again
+		}
+
+		delay, fromServer := retryDelay(resp, attempt, c.cfg.RetryBaseDelay, c.cfg.RetryMaxDelay)
+		if delay > budget {
+			if fromServer {
+				log.Printf("Upstream %s %s: server asked to retry in %s, beyond the retry budget", req.Method, req.URL.Path, delay)
+			}
+			return resp, err
+		}
+		budget -= delay
+
+		log.Printf("Upstream %s %s attempt %d/%d failed (%s), retrying in %s",
+			req.Method, req.URL.Path, attempt+1, c.cfg.MaxRetries+1, failure(resp, err), delay.Round(time.Millisecond))
+		if resp != nil {
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("replaying request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryable reports whether an attempt failed in a way worth retrying.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		// A request that used up its whole timeout would most likely do so again
+		return !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// failure describes a failed attempt for the log.
+func failure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+// retryDelay returns how long to wait before the next attempt and whether the
+// server asked for that delay.
+func retryDelay(resp *http.Response, attempt int, base, maxDelay time.Duration) (time.Duration, bool) {
+	if resp != nil {
+		if delay, ok := serverRetryDelay(resp.Header); ok {
+			return delay, true
+		}
+	}
+
+	// Exponential backoff with equal jitter: half fixed, half random
+	delay := base << attempt
+	if delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+	return delay, false
+}
+
+// serverRetryDelay reads the delay requested by Retry-After (seconds or an
+// HTTP date), retry-after-ms, or the x-ratelimit-reset-* header of whichever
+// rate limit is exhausted.
+func serverRetryDelay(header http.Header) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			if delay := time.Until(date); delay > 0 {
+				return delay, true
+			}
+			return 0, true
+		}
+	}
+
+	var delay time.Duration
+	found := false
+	for _, limit := range []string{"requests", "tokens"} {
+		if header.Get("x-ratelimit-remaining-"+limit) != "0" {
+			continue
+		}
+		if reset, err := time.ParseDuration(header.Get("x-ratelimit-reset-" + limit)); err == nil && reset > delay {
+			delay, found = reset, true
+		}
+	}
+	return delay, found
+}
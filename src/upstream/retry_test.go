@@ -0,0 +1,152 @@
+package upstream
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ollama-openai-proxy/src/config"
+)
+
+// retryConfig retries quickly so tests stay fast.
+func retryConfig(maxRetries int) config.UpstreamConfig {
+	return config.UpstreamConfig{
+		MaxRetries:     maxRetries,
+		RetryBaseDelay: time.Millisecond,
+		RetryMaxDelay:  10 * time.Millisecond,
+		RetryBudget:    time.Second,
+	}
+}
+
+func post(t *testing.T, client *Client, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestClient_RetriesTransientErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"model":"gpt-4o"}` {
+			t.Errorf("Attempt %d got body %q", atomic.LoadInt32(&attempts)+1, body)
+		}
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("x-ratelimit-remaining-requests", "0")
+			w.Header().Set("x-ratelimit-reset-requests", "20ms")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	resp := post(t, newTestClient(t, retryConfig(2)), server.URL, `{"model":"gpt-4o"}`)
+	if resp.StatusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestClient_RetryLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		header       map[string]string
+		maxRetries   int
+		wantAttempts int32
+	}{
+		{"gives up after max retries", http.StatusServiceUnavailable, nil, 2, 3},
+		{"client errors are not retried", http.StatusBadRequest, nil, 2, 1},
+		{"not implemented is not retried", http.StatusNotImplemented, nil, 2, 1},
+		{"retries disabled", http.StatusServiceUnavailable, nil, 0, 1},
+		{"retry-after beyond budget", http.StatusTooManyRequests, map[string]string{"Retry-After": "120"}, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			resp := post(t, newTestClient(t, retryConfig(tt.maxRetries)), server.URL, "{}")
+			if resp.StatusCode != tt.status || attempts != tt.wantAttempts {
+				t.Errorf("Got %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.status, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestClient_RetriesConnectionErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// Drop the connection without answering
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	resp := post(t, newTestClient(t, retryConfig(1)), server.URL, "{}")
+	if resp.StatusCode != http.StatusOK || attempts != 2 {
+		t.Errorf("Expected success after a dropped connection, got %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestServerRetryDelay(t *testing.T) {
+	tests := []struct {
+		header map[string]string
+		want   time.Duration
+		ok     bool
+	}{
+		{map[string]string{"Retry-After": "3"}, 3 * time.Second, true},
+		{map[string]string{"retry-after-ms": "250"}, 250 * time.Millisecond, true},
+		{map[string]string{"x-ratelimit-remaining-tokens": "0", "x-ratelimit-reset-tokens": "6m0s", "x-ratelimit-reset-requests": "1s"}, 6 * time.Minute, true},
+		{map[string]string{"x-ratelimit-remaining-tokens": "10", "x-ratelimit-reset-tokens": "6m0s"}, 0, false},
+		{map[string]string{}, 0, false},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		for key, value := range tt.header {
+			header.Set(key, value)
+		}
+		if got, ok := serverRetryDelay(header); got != tt.want || ok != tt.ok {
+			t.Errorf("serverRetryDelay(%v) = %s, %v; want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRetryDelay_Backoff(t *testing.T) {
+	for attempt := 0; attempt < 6; attempt++ {
+		delay, fromServer := retryDelay(nil, attempt, 100*time.Millisecond, time.Second)
+		ceiling := 100 * time.Millisecond << attempt
+		if ceiling > time.Second {
+			ceiling = time.Second
+		}
+		if fromServer || delay < ceiling/2 || delay > ceiling {
+			t.Errorf("Attempt %d: delay %s outside [%s, %s]", attempt, delay, ceiling/2, ceiling)
+		}
+	}
+}